@@ -0,0 +1,579 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"hugotranslationstudy/internal/frontmatter"
+	"hugotranslationstudy/internal/po"
+	"hugotranslationstudy/internal/subtokenize"
+	"hugotranslationstudy/internal/tm"
+	"hugotranslationstudy/internal/xliff"
+)
+
+// Unit ids tie interchange units back to data.json: "t<i>" is the i-th text
+// span, "p<i>" the i-th shortcode parameter and "fm<i>" the i-th front
+// matter field.
+const (
+	unitText  = "t"
+	unitParam = "p"
+	unitFM    = "fm"
+)
+
+// xliffFile builds the XLIFF units of one page. Spans without any
+// non-whitespace text are left out; the importer keeps their source.
+func xliffFile(in Output) xliff.File {
+	f := xliff.File{Original: filepath.ToSlash(in.SourcePath)}
+	for i, subs := range spanSubtokens(in) {
+		if len(subs) == 0 {
+			subs = []subtokenize.Subtoken{{Type: "text", Val: in.ContentTextSpans[i].Text}}
+		}
+		if !hasText(subs) {
+			continue
+		}
+		f.Units = append(f.Units, xliff.Unit{ID: unitText + strconv.Itoa(i), Subtokens: subs})
+	}
+	for i, p := range in.ContentParamSpans {
+		f.Units = append(f.Units, xliff.Unit{
+			ID:        unitParam + strconv.Itoa(i),
+			Name:      p.Shortcode + "." + p.Param,
+			Subtokens: []subtokenize.Subtoken{{Type: "text", Val: p.Text}},
+		})
+	}
+	for i, fm := range in.FrontMatterFields {
+		f.Units = append(f.Units, xliff.Unit{
+			ID:        unitFM + strconv.Itoa(i),
+			Name:      fm.Path,
+			Subtokens: []subtokenize.Subtoken{{Type: "text", Val: fm.Text}},
+		})
+	}
+	return f
+}
+
+func hasText(subs []subtokenize.Subtoken) bool {
+	for _, s := range subs {
+		if s.Type == "text" && strings.TrimSpace(s.Val) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeXLIFF writes the translatable segments of one page as XLIFF 2.0.
+func writeXLIFF(outPath string, in Output, sourceLocale, targetLocale string) error {
+	f, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", outPath, err)
+	}
+	defer f.Close()
+	if err := xliff.Write(f, sourceLocale, targetLocale, []xliff.File{xliffFile(in)}); err != nil {
+		return fmt.Errorf("write %s: %w", outPath, err)
+	}
+	return f.Close()
+}
+
+// runImport implements `hugotranslationstudy import`: it reads translated
+// XLIFF or PO files and rebuilds each page they cover from the page's
+// data.json, as translated.md or, with several locales, as
+// translated.<locale>.md, plus the page in the Hugo site tree when the run
+// writes one. Units without a translation keep their source text.
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	locale := fs.String("locale", "", "locale of the translations (default: the XLIFF trgLang or PO Language)")
+	sourceLocale := fs.String("source-locale", "en", "locale of the content files")
+	locales := fs.String("locales", "", "comma-separated target locales, as for the run that exported the files")
+	layout := fs.String("layout", layoutFilename, `where translations go in out/hugo: by "filename" (post.fr.md) or by content "directory" (fr/post.md)`)
+	hugoConfig := fs.String("hugo-config", "", "Hugo site config to read languages from (default: hugo.toml, config.yaml, ... in the current directory)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s import [flags] file.xlf|file.po...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *layout != layoutFilename && *layout != layoutDirectory {
+		log.Fatalf("unknown -layout %q", *layout)
+	}
+
+	// The same locales as the run that exported the files, so the
+	// translations land where that run put its own
+	sourceSet := false
+	fs.Visit(func(f *flag.Flag) { sourceSet = sourceSet || f.Name == "source-locale" })
+	setup, err := resolveLocales(*locales, *hugoConfig, "", sourceLocale, sourceSet)
+	if err != nil {
+		log.Fatalf("locales: %v", err)
+	}
+	multi := len(setup.Locales) > 1
+
+	for _, path := range fs.Args() {
+		var d delivery
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".po":
+			d = readPOUnits(path)
+		default:
+			d = readXLIFFUnits(path)
+		}
+
+		lang := *locale
+		if lang == "" {
+			lang = d.Locale
+		}
+		if setup.WriteSite {
+			if lang == "" && len(setup.Locales) == 1 {
+				lang = setup.Locales[0]
+			}
+			if !slices.Contains(setup.Locales, lang) {
+				log.Fatalf("%s: locale %q is not one of the target locales %s; pass -locale",
+					path, lang, strings.Join(setup.Locales, ","))
+			}
+		}
+
+		for _, page := range d.Pages {
+			dir := filepath.Dir(pageJSON(page))
+			if !multi && lang != "" {
+				// A lone translated.md would sit next to the stale translations
+				if _, err := os.Stat(filepath.Join(dir, localized("translated.md", lang, true))); err == nil {
+					log.Fatalf("%s: %s was exported for several locales; pass the same -locales as the export", path, page)
+				}
+			}
+			fm, body := importTranslations(pageJSON(page), d.Units[page])
+			mdOut := filepath.Join(dir, localized("translated.md", lang, multi))
+			writeHugoFile(mdOut, fm, body)
+			fmt.Printf("Imported %s -> %s\n", filepath.ToSlash(path), filepath.ToSlash(mdOut))
+
+			if setup.WriteSite {
+				siteOut, err := sitePath(filepath.FromSlash(page), lang, *sourceLocale, *layout, setup.Site)
+				if err != nil {
+					log.Fatal(err)
+				}
+				if err := os.MkdirAll(filepath.Dir(siteOut), 0o755); err != nil {
+					log.Fatalf("mkdir %s: %v", filepath.Dir(siteOut), err)
+				}
+				writeHugoFile(siteOut, fm, body)
+				fmt.Printf("Imported %s -> %s\n", filepath.ToSlash(path), filepath.ToSlash(siteOut))
+			}
+		}
+	}
+}
+
+// delivery is a translated XLIFF or PO file.
+type delivery struct {
+	Locale  string                       // the target locale the file names, if any
+	Pages   []string                     // the pages it covers, in order of first appearance
+	Units   map[string]map[string]string // page -> unit id -> translation
+	Damaged map[string]map[string]error  // page -> unit id -> what is wrong with its inline codes
+}
+
+// pageJSON returns the data.json written for a content file.
+func pageJSON(page string) string {
+	targetDir, err := targetDirFor(contentRoot, outRoot, filepath.FromSlash(page))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return filepath.Join(targetDir, "data.json")
+}
+
+// readXLIFFUnits reads a translated XLIFF file and groups its targets by
+// page. A target that drops, repeats or adds an inline code is fatal.
+func readXLIFFUnits(path string) delivery {
+	d, err := loadXLIFFUnits(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, page := range d.Pages {
+		units := slices.Sorted(maps.Keys(d.Damaged[page]))
+		if len(units) > 0 {
+			log.Fatalf("%s: %s: unit %s: %v", path, page, units[0], d.Damaged[page][units[0]])
+		}
+	}
+	return d
+}
+
+// loadXLIFFUnits is readXLIFFUnits returning an error instead of exiting.
+// Targets with damaged inline codes are kept, and noted in Damaged.
+func loadXLIFFUnits(path string) (delivery, error) {
+	r, err := os.Open(path)
+	if err != nil {
+		return delivery{}, err
+	}
+	defer r.Close()
+	doc, err := xliff.ReadDocument(r)
+	if err != nil {
+		return delivery{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	d := delivery{Locale: doc.TrgLang, Units: map[string]map[string]string{}, Damaged: map[string]map[string]error{}}
+	for _, t := range doc.Targets {
+		if d.Units[t.File] == nil {
+			d.Units[t.File] = map[string]string{}
+			d.Damaged[t.File] = map[string]error{}
+			d.Pages = append(d.Pages, t.File)
+		}
+		d.Units[t.File][t.Unit] = t.Text
+		if t.Err != nil {
+			d.Damaged[t.File][t.Unit] = t.Err
+		}
+	}
+	return d, nil
+}
+
+// importTranslations rebuilds a page from data.json and translated units,
+// keyed by unit id.
+func importTranslations(jsonPath string, units map[string]string) (*frontmatter.Document, string) {
+	in := readOutput(jsonPath)
+	pick := func(id, source string) string {
+		if t, ok := units[id]; ok {
+			return t
+		}
+		return source
+	}
+
+	spans := make([]string, len(in.ContentTextSpans))
+	for i, s := range in.ContentTextSpans {
+		spans[i] = pick(unitText+strconv.Itoa(i), s.Text)
+	}
+	params := make([]string, len(in.ContentParamSpans))
+	for i, p := range in.ContentParamSpans {
+		params[i] = pick(unitParam+strconv.Itoa(i), p.Text)
+	}
+	fields := make([]string, len(in.FrontMatterFields))
+	for i, f := range in.FrontMatterFields {
+		fields[i] = pick(unitFM+strconv.Itoa(i), f.Text)
+	}
+	return applyTranslations(jsonPath, in, spans, params, fields)
+}
+
+/* ---------------------------------- PO ----------------------------------- */
+
+// poMessage is a PO entry plus where its translation goes. Markup and
+// whitespace at the edges of a span stay out of the msgid; they are kept in
+// prefix and suffix and put back around the msgstr.
+type poMessage struct {
+	unit           string
+	prefix, suffix string
+	subs           []subtokenize.Subtoken // of a text span, to escape its attribute values
+	entry          po.Entry
+}
+
+// poMessages builds the PO messages of one page. msgctxt is
+// "<path>:<offset>" with the byte offset of the text in the source file, or
+// "<path>:fm:<field path>" for front matter values.
+func poMessages(in Output) []poMessage {
+	page := filepath.ToSlash(in.SourcePath)
+	file := in.FrontMatterRaw + in.ContentRaw
+	at := func(bodyOffset int) (ctx, ref string) {
+		off := len(in.FrontMatterRaw) + bodyOffset
+		line := strings.Count(file[:off], "\n") + 1
+		return page + ":" + strconv.Itoa(off), page + ":" + strconv.Itoa(line)
+	}
+
+	var msgs []poMessage
+	for i, subs := range spanSubtokens(in) {
+		span := in.ContentTextSpans[i]
+		lo, hi, ok := spanCore(span.Text, subs)
+		if !ok {
+			continue
+		}
+		ctx, ref := at(span.Start + lo)
+		msgs = append(msgs, poMessage{
+			unit:   unitText + strconv.Itoa(i),
+			prefix: span.Text[:lo],
+			suffix: span.Text[hi:],
+			subs:   subs,
+			entry:  po.Entry{Context: ctx, ID: span.Text[lo:hi], References: []string{ref}},
+		})
+	}
+	for i, p := range in.ContentParamSpans {
+		ctx, ref := at(p.Start + len(p.Quote))
+		msgs = append(msgs, poMessage{
+			unit: unitParam + strconv.Itoa(i),
+			entry: po.Entry{
+				Context:    ctx,
+				ID:         p.Text,
+				Comments:   []string{fmt.Sprintf("Shortcode %s, parameter %s", p.Shortcode, p.Param)},
+				References: []string{ref},
+			},
+		})
+	}
+	for i, f := range in.FrontMatterFields {
+		msgs = append(msgs, poMessage{
+			unit: unitFM + strconv.Itoa(i),
+			entry: po.Entry{
+				Context:  page + ":fm:" + f.Path,
+				ID:       f.Text,
+				Comments: []string{"Front matter " + f.Path},
+			},
+		})
+	}
+
+	// Empty values have no msgid to translate
+	kept := msgs[:0]
+	for _, m := range msgs {
+		if m.entry.ID != "" {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// spanCore finds the part of a span from its first to its last non-blank
+// text subtoken. It falls back to the trimmed span when subtokens are missing
+// or do not cover the span exactly.
+func spanCore(text string, subs []subtokenize.Subtoken) (lo, hi int, ok bool) {
+	var joined strings.Builder
+	for _, s := range subs {
+		joined.WriteString(s.Val)
+	}
+	if len(subs) == 0 || joined.String() != text {
+		lo = len(text) - len(strings.TrimLeftFunc(text, unicode.IsSpace))
+		hi = len(strings.TrimRightFunc(text, unicode.IsSpace))
+		return lo, hi, lo < hi
+	}
+
+	lo, hi = -1, -1
+	pos := 0
+	for _, s := range subs {
+		if s.Type == "text" && strings.TrimSpace(s.Val) != "" {
+			start := pos + len(s.Val) - len(strings.TrimLeftFunc(s.Val, unicode.IsSpace))
+			if lo < 0 {
+				lo = start
+			}
+			hi = pos + len(strings.TrimRightFunc(s.Val, unicode.IsSpace))
+		}
+		pos += len(s.Val)
+	}
+	return lo, hi, lo >= 0
+}
+
+// sectionOf returns the Hugo section of a content file: its top-level
+// directory, or "home" for files directly under the content root.
+func sectionOf(path string) string {
+	rel, err := filepath.Rel(contentRoot, path)
+	if err != nil {
+		return "home"
+	}
+	if dir, _, found := strings.Cut(filepath.ToSlash(rel), "/"); found {
+		return dir
+	}
+	return "home"
+}
+
+// writePOT writes the messages of one or more pages as a POT template.
+func writePOT(outPath string, pages []Output) error {
+	var entries []po.Entry
+	for _, in := range pages {
+		for _, m := range poMessages(in) {
+			entries = append(entries, m.entry)
+		}
+	}
+	f, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", outPath, err)
+	}
+	defer f.Close()
+	if err := po.Write(f, po.Header{Project: "hugotranslationstudy"}, entries); err != nil {
+		return fmt.Errorf("write %s: %w", outPath, err)
+	}
+	return f.Close()
+}
+
+// readPOUnits reads a translated PO file and maps its entries back to unit
+// ids of each page's data.json. Fuzzy and untranslated entries are skipped,
+// so those segments keep the source text.
+func readPOUnits(path string) delivery {
+	d, err := loadPOUnits(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return d
+}
+
+// loadPOUnits is readPOUnits returning an error instead of exiting.
+func loadPOUnits(path string) (delivery, error) {
+	r, err := os.Open(path)
+	if err != nil {
+		return delivery{}, err
+	}
+	defer r.Close()
+	header, entries, err := po.ReadWithHeader(r)
+	if err != nil {
+		return delivery{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var pages []string
+	byCtx := map[string]map[string]po.Entry{}
+	for _, e := range entries {
+		page, ok := poPage(e.Context)
+		if !ok {
+			log.Printf("warning: %s: unrecognized msgctxt %q", path, e.Context)
+			continue
+		}
+		if byCtx[page] == nil {
+			byCtx[page] = map[string]po.Entry{}
+			pages = append(pages, page)
+		}
+		byCtx[page][e.Context] = e
+	}
+
+	d := delivery{Locale: header.Language, Pages: pages, Units: map[string]map[string]string{}}
+	for _, page := range pages {
+		units := map[string]string{}
+		for _, m := range poMessages(readOutput(pageJSON(page))) {
+			e, ok := byCtx[page][m.entry.Context]
+			if !ok || e.Fuzzy || e.Str == "" {
+				continue
+			}
+			if e.ID != m.entry.ID {
+				log.Printf("warning: %s: %s: source text changed since export, skipping", path, e.Context)
+				continue
+			}
+			units[m.unit] = escapeAttributes(m.prefix+e.Str+m.suffix, m.subs)
+		}
+		d.Units[page] = units
+	}
+	return d, nil
+}
+
+// escapeAttributes escapes the quotes in the attribute values and titles of
+// text, the translation of a span with subtokens subs. A value starts after
+// the markup in front of it and runs up to the first following occurrence
+// of the markup after it.
+func escapeAttributes(text string, subs []subtokenize.Subtoken) string {
+	var b strings.Builder
+	pos := 0
+	for i, s := range subs {
+		if s.Attr == "" || i == 0 || i+1 == len(subs) {
+			continue
+		}
+		before, after := subs[i-1].Val, subs[i+1].Val
+		j := strings.Index(text[pos:], before)
+		if j < 0 {
+			continue
+		}
+		start := pos + j + len(before)
+		k := strings.Index(text[start:], after)
+		if k < 0 {
+			continue
+		}
+		b.WriteString(text[pos:start])
+		b.WriteString(subtokenize.EscapeAttribute(text[start:start+k], before))
+		pos = start + k
+	}
+	b.WriteString(text[pos:])
+	return b.String()
+}
+
+// poPage returns the content path a msgctxt belongs to.
+func poPage(ctx string) (string, bool) {
+	if page, _, found := strings.Cut(ctx, ":fm:"); found {
+		return page, true
+	}
+	i := strings.LastIndexByte(ctx, ':')
+	if i < 0 {
+		return "", false
+	}
+	if _, err := strconv.Atoi(ctx[i+1:]); err != nil {
+		return "", false
+	}
+	return ctx[:i], true
+}
+
+/* ---------------------------------- TMX ---------------------------------- */
+
+// runTM implements `hugotranslationstudy tm import|export`, which moves the
+// translation memory to and from TMX.
+func runTM(args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "usage: %s tm import -tm file.jsonl file.tmx...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s tm export -tm file.jsonl -o file.tmx\n", os.Args[0])
+		os.Exit(2)
+	}
+	if len(args) == 0 {
+		usage()
+	}
+
+	fs := flag.NewFlagSet("tm "+args[0], flag.ExitOnError)
+	tmPath := fs.String("tm", "", "translation memory file (JSONL)")
+	switch args[0] {
+	case "import":
+		sourceLocale := fs.String("source-locale", "", "only import this source language (e.g. en also takes en-US) and store it under this name")
+		targetLocale := fs.String("target-locale", "", "only import this target language, stored under this name")
+		fs.Parse(args[1:])
+		if *tmPath == "" || fs.NArg() == 0 {
+			usage()
+		}
+		mem, err := tm.Open(*tmPath)
+		if err != nil {
+			log.Fatalf("translation memory: %v", err)
+		}
+		for _, path := range fs.Args() {
+			r, err := os.Open(path)
+			if err != nil {
+				log.Fatal(err)
+			}
+			entries, skipped, err := tm.ReadTMX(r)
+			r.Close()
+			if err != nil {
+				log.Fatalf("%s: %v", path, err)
+			}
+
+			added := 0
+			for _, e := range entries {
+				if *sourceLocale != "" {
+					if !tm.LocaleMatches(e.SourceLocale, *sourceLocale) {
+						continue
+					}
+					e.SourceLocale = *sourceLocale
+				}
+				if *targetLocale != "" {
+					if !tm.LocaleMatches(e.TargetLocale, *targetLocale) {
+						continue
+					}
+					e.TargetLocale = *targetLocale
+				}
+				mem.Add(e.Source, e.Target, e.SourceLocale, e.TargetLocale)
+				added++
+			}
+			fmt.Printf("Imported %d entries from %s (%d units skipped: inline codes do not line up)\n",
+				added, filepath.ToSlash(path), skipped)
+		}
+		if err := mem.Save(); err != nil {
+			log.Fatalf("translation memory: %v", err)
+		}
+
+	case "export":
+		outPath := fs.String("o", "", "TMX file to write")
+		fs.Parse(args[1:])
+		if *tmPath == "" || *outPath == "" {
+			usage()
+		}
+		mem, err := tm.Open(*tmPath)
+		if err != nil {
+			log.Fatalf("translation memory: %v", err)
+		}
+		f, err := os.Create(*outPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := tm.WriteTMX(f, mem.Entries()); err != nil {
+			log.Fatalf("write %s: %v", *outPath, err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Exported %d entries to %s\n", mem.Len(), filepath.ToSlash(*outPath))
+
+	default:
+		usage()
+	}
+}
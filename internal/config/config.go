@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config controls which parts of a content file are sent to the translator.
+// Anything not listed here is protected.
+type Config struct {
+	// Shortcodes maps a shortcode name to its translatable parameters.
+	Shortcodes map[string]ShortcodeParams `yaml:"shortcodes"`
+	// FrontMatter lists translatable front matter keys as dot-separated
+	// paths, e.g. "title", "tags" or "menu.main.name".
+	FrontMatter []string `yaml:"frontMatter"`
+	// HTMLAttributes lists the HTML attributes whose values are text, e.g.
+	// "alt" in <img alt="A diagram">. Unset means alt, title, aria-label
+	// and placeholder; an empty list means none.
+	HTMLAttributes []string `yaml:"htmlAttributes"`
+	// Abbreviations lists, per language, words ending in a period that do
+	// not end a sentence, on top of the built-in ones, e.g. "en": [approx].
+	Abbreviations map[string][]string `yaml:"abbreviations"`
+}
+
+// ShortcodeParams lists the translatable parameters of one shortcode.
+type ShortcodeParams struct {
+	// Params are named parameters, e.g. "text" in {{< badge text="NEW" >}}.
+	Params []string `yaml:"params"`
+	// Positional are zero-based positions, e.g. 0 in {{< note "Hi" >}}.
+	Positional []int `yaml:"positional"`
+}
+
+// Load reads a YAML config file.
+func Load(path string) (Config, error) {
+	var cfg Config
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// TranslatableParam reports whether the named parameter of shortcode should
+// be translated.
+func (c Config) TranslatableParam(shortcode, param string) bool {
+	for _, p := range c.Shortcodes[shortcode].Params {
+		if p == param {
+			return true
+		}
+	}
+	return false
+}
+
+// TranslatablePosition reports whether the positional parameter at index pos
+// of shortcode should be translated.
+func (c Config) TranslatablePosition(shortcode string, pos int) bool {
+	for _, p := range c.Shortcodes[shortcode].Positional {
+		if p == pos {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "translation.yaml")
+	src := `
+shortcodes:
+  badge:
+    params: [text]
+  note:
+    positional: [0]
+frontMatter:
+  - title
+  - menu.main.name
+htmlAttributes: [alt, aria-label]
+abbreviations:
+  en: [approx]
+`
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		got  bool
+		want bool
+	}{
+		{"badge text", cfg.TranslatableParam("badge", "text"), true},
+		{"badge color", cfg.TranslatableParam("badge", "color"), false},
+		{"unknown shortcode", cfg.TranslatableParam("icon", "name"), false},
+		{"note position 0", cfg.TranslatablePosition("note", 0), true},
+		{"note position 1", cfg.TranslatablePosition("note", 1), false},
+		{"badge position 0", cfg.TranslatablePosition("badge", 0), false},
+	}
+	for _, tc := range tests {
+		if tc.got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+
+	if len(cfg.FrontMatter) != 2 || cfg.FrontMatter[1] != "menu.main.name" {
+		t.Errorf("FrontMatter = %v", cfg.FrontMatter)
+	}
+	if len(cfg.HTMLAttributes) != 2 || cfg.HTMLAttributes[1] != "aria-label" {
+		t.Errorf("HTMLAttributes = %v", cfg.HTMLAttributes)
+	}
+	if a := cfg.Abbreviations["en"]; len(a) != 1 || a[0] != "approx" {
+		t.Errorf("Abbreviations = %v", cfg.Abbreviations)
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Load(filepath.Join(t.TempDir(), "nope.yaml")); !os.IsNotExist(err) {
+		t.Errorf("expected a not-exist error, got %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package frommarkdoc
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"hugotranslationstudy/internal/markdoc"
+	"hugotranslationstudy/internal/tomarkdoc"
+)
+
+// Convert turns the Markdoc tags of a document back into Hugo shortcodes,
+// reversing the mapping in schema: tags and attributes get their shortcode
+// names back, injected defaults are removed and positional parameters are
+// restored. A tag marked with the markup attribute, or a shortcode with
+// notation "%", is written as {{% %}}. Everything but the tags, front
+// matter and code included, is copied as is. Tags that cannot be converted
+// are kept and reported.
+//
+// Shortcodes that the schema drops or turns into Markdown cannot be
+// recovered.
+func Convert(src string, schema tomarkdoc.Schema) (string, []tomarkdoc.Diagnostic) {
+	tags, errs := markdoc.Parse([]byte(src))
+	c := &converter{schema: schema}
+	for _, e := range errs {
+		c.report(e.Offset, "", "%s", e.Message)
+	}
+
+	var out strings.Builder
+	pos := 0
+	for _, t := range tags {
+		out.WriteString(src[pos:t.Start])
+		pos = t.End
+		raw := src[t.Start:t.End]
+		switch {
+		case t.Name == "":
+			c.report(t.Start, "", "annotation %s has no Hugo equivalent", raw)
+			out.WriteString(raw)
+		case t.Closing:
+			out.WriteString(c.closing(t, raw))
+		default:
+			out.WriteString(c.opening(t))
+		}
+	}
+	out.WriteString(src[pos:])
+	return out.String(), c.diags
+}
+
+type converter struct {
+	schema tomarkdoc.Schema
+	open   []opened // tags open at this point, innermost last
+	diags  []tomarkdoc.Diagnostic
+}
+
+// opened is an open Markdoc tag and the shortcode it was turned into.
+type opened struct {
+	tag, shortcode, notation string
+}
+
+func (c *converter) report(offset int, shortcode, format string, args ...any) {
+	c.diags = append(c.diags, tomarkdoc.Diagnostic{Offset: offset, Shortcode: shortcode, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *converter) opening(t markdoc.Tag) string {
+	name := c.shortcodeFor(t)
+	sc := c.schema.Shortcodes[name]
+	notation := sc.Notation
+	if v, ok := t.Attr(c.schema.MarkupAttribute); ok && v == true {
+		notation = "%"
+	}
+	if notation != "%" {
+		notation = "<"
+	}
+	if !t.SelfClosing {
+		c.open = append(c.open, opened{tag: t.Name, shortcode: name, notation: notation})
+	}
+	return delimited(notation, strings.Join(append([]string{name}, c.params(t, name, sc)...), " "))
+}
+
+func (c *converter) closing(t markdoc.Tag, raw string) string {
+	for i := len(c.open) - 1; i >= 0; i-- {
+		if o := c.open[i]; o.tag == t.Name {
+			c.open = c.open[:i]
+			return delimited(o.notation, "/"+o.shortcode)
+		}
+	}
+	c.report(t.Start, t.Name, "closing tag without an opening tag")
+	return raw
+}
+
+func delimited(notation, inner string) string {
+	if notation == "%" {
+		return "{{% " + inner + " %}}"
+	}
+	return "{{< " + inner + " >}}"
+}
+
+// shortcodeFor picks the shortcode a tag came from. Several shortcodes can
+// map onto one tag, such as note and admonition onto callout; each
+// candidate scores a point for every default the tag carries and for every
+// attribute it renames, and loses one for every default the tag lacks.
+func (c *converter) shortcodeFor(t markdoc.Tag) string {
+	var candidates []string
+	for name := range c.schema.Shortcodes {
+		if c.schema.Tag(name) == t.Name {
+			candidates = append(candidates, name)
+		}
+	}
+	if _, ok := c.schema.Shortcodes[t.Name]; !ok {
+		candidates = append(candidates, t.Name) // the tag kept its name
+	}
+	sort.Strings(candidates)
+
+	var best []string
+	bestScore := 0
+	for _, name := range candidates {
+		sc := c.schema.Shortcodes[name]
+		score := 0
+		for k, v := range sc.Defaults {
+			got, ok := t.Attr(k)
+			switch {
+			case !ok:
+				score--
+			case fmt.Sprint(got) == fmt.Sprint(v):
+				score++
+			}
+		}
+		for _, attr := range sc.Attributes {
+			if _, ok := t.Attr(attr); ok {
+				score++
+			}
+		}
+		if len(best) == 0 || score > bestScore {
+			best, bestScore = nil, score
+		}
+		if score == bestScore {
+			best = append(best, name)
+		}
+	}
+	for _, name := range best {
+		if name == t.Name {
+			return name
+		}
+	}
+	if len(best) > 1 {
+		c.report(t.Start, t.Name, "tag matches shortcodes %s; using %s", strings.Join(best, ", "), best[0])
+	}
+	return best[0]
+}
+
+// params turns the attributes of t back into shortcode parameters.
+func (c *converter) params(t markdoc.Tag, name string, sc tomarkdoc.ShortcodeSchema) []string {
+	hugoName := map[string]string{}
+	for param, attr := range sc.Attributes {
+		hugoName[attr] = param
+	}
+
+	type param struct {
+		name  string
+		value any
+	}
+	var params []param
+	for _, a := range t.Attributes {
+		if d, ok := sc.Defaults[a.Name]; ok && fmt.Sprint(d) == fmt.Sprint(a.Value) {
+			continue // injected by the migration
+		}
+		if a.Name != "" && a.Name == c.schema.MarkupAttribute && a.Value == true {
+			continue // the {{% %}} notation
+		}
+		if a.Value == nil {
+			c.report(t.Start, name, "attribute %s is null; left out", a.Name)
+			continue
+		}
+		n := a.Name
+		if h, ok := hugoName[n]; ok {
+			n = h
+		}
+		params = append(params, param{n, a.Value})
+	}
+
+	// Positional if every parameter is one, in schema order.
+	positional := len(params) > 0
+	for i, p := range params {
+		positional = positional && (p.name == "" || i < len(sc.Positional) && sc.Positional[i] == p.name)
+	}
+	var out []string
+	for i, p := range params {
+		switch {
+		case positional:
+			out = append(out, hugoValue(p.value))
+		case p.name == "":
+			c.report(t.Start, name, "primary attribute mixed with named ones; written as parameter %d", i)
+			out = append(out, hugoValue(p.value))
+		default:
+			out = append(out, p.name+"="+hugoValue(p.value))
+		}
+	}
+	return out
+}
+
+// hugoValue renders a parameter value as Hugo shortcode syntax. Strings
+// with quotes or backslashes use a raw `string` when they can.
+func hugoValue(v any) string {
+	switch v := v.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	s := fmt.Sprint(v)
+	if strings.ContainsAny(s, "\"\\\n") && !strings.Contains(s, "`") {
+		return "`" + s + "`"
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,155 @@
+package frommarkdoc
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"hugotranslationstudy/internal/tomarkdoc"
+
+	"github.com/gohugoio/hugo/parser/pageparser"
+)
+
+var schema = tomarkdoc.Schema{Shortcodes: map[string]tomarkdoc.ShortcodeSchema{
+	"note":       {Positional: []string{"text"}, Tag: "callout", Defaults: map[string]any{"type": "note"}},
+	"admonition": {Tag: "callout", Attributes: map[string]string{"type": "kind"}, Notation: "%"},
+	"panel":      {Notation: "%"},
+	"tag":        {Notation: "%"},
+	"figure":     {Positional: []string{"src", "alt"}},
+}, MarkupAttribute: "markdown"}
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		in    string
+		want  string
+		diags []string
+	}{
+		{
+			name: "typed attributes",
+			in:   `{% chart width=640 ratio=1.5 legend=true title="Sales" /%}`,
+			want: `{{< chart width=640 ratio=1.5 legend=true title="Sales" >}}`,
+		},
+		{
+			name: "renamed tag, default removed, positional restored",
+			in:   `A {% callout text="Hi" type="note" /%} B`,
+			want: `A {{< note "Hi" >}} B`,
+		},
+		{
+			name: "renamed attribute with notation",
+			in:   "{% callout kind=\"tip\" %}\nBody\n{% /callout %}",
+			want: "{{% admonition type=\"tip\" %}}\nBody\n{{% /admonition %}}",
+		},
+		{
+			name: "markup attribute",
+			in:   `{% box markdown=true %}x{% /box %} {% panel markdown=false /%}`,
+			want: `{{% box %}}x{{% /box %}} {{% panel markdown=false %}}`,
+		},
+		{
+			name: "nested",
+			in:   `{% panel %}{% box title="T" %}x{% /box %}{% /panel %}`,
+			want: `{{% panel %}}{{< box title="T" >}}x{{< /box >}}{{% /panel %}}`,
+		},
+		{
+			name: "positional out of order stays named",
+			in:   `{% figure alt="A" src="a.png" /%}`,
+			want: `{{< figure alt="A" src="a.png" >}}`,
+		},
+		{
+			name: "quotes in values",
+			in:   `{% q a="say \"hi\"" b="it's" /%}`,
+			want: "{{< q a=`say \"hi\"` b=\"it's\" >}}",
+		},
+		{
+			name: "code untouched",
+			in:   "`{% x /%}`\n\n```\n{% y %}\n```\n",
+			want: "`{% x /%}`\n\n```\n{% y %}\n```\n",
+		},
+		{
+			name: "problems",
+			in:   `{% .big %} {% /box %} {% x "p" y=1 /%} {% callout /%}`,
+			want: `{% .big %} {% /box %} {{< x "p" y=1 >}} {{< callout >}}`,
+			diags: []string{
+				"annotation {% .big %} has no Hugo equivalent",
+				"box: closing tag without an opening tag",
+				"x: primary attribute mixed with named ones; written as parameter 0",
+			},
+		},
+		{
+			name: "tie goes to the tag's own name",
+			in:   `{% callout /%}`,
+			want: `{{< callout >}}`,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, diags := Convert(tc.in, schema)
+			if got != tc.want {
+				t.Fatalf("\nConvert(%q)\n  got : %q\n  want: %q", tc.in, got, tc.want)
+			}
+			var msgs []string
+			for _, d := range diags {
+				if d.Shortcode != "" {
+					d.Message = d.Shortcode + ": " + d.Message
+				}
+				msgs = append(msgs, d.Message)
+			}
+			if strings.Join(msgs, "\n") != strings.Join(tc.diags, "\n") {
+				t.Fatalf("diagnostics\n  got : %q\n  want: %q", msgs, tc.diags)
+			}
+		})
+	}
+}
+
+// shortcodeRe matches a Hugo shortcode tag on one line.
+var shortcodeRe = regexp.MustCompile(`\{\{[<%].*?[>%]\}\}`)
+
+// TestRoundTrip migrates the content fixtures to Markdoc and back. The Hugo
+// that comes back must migrate to the same Markdoc, and differ from the
+// original only in the spacing inside shortcode tags.
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	paths, err := filepath.Glob("../../content/*.md")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no fixtures: %v", err)
+	}
+	for _, path := range paths {
+		path := path
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			t.Parallel()
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cf, err := pageparser.ParseFrontMatterAndContent(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			orig := string(cf.Content)
+
+			mdoc := tomarkdoc.Convert(orig, schema).Body
+			back, diags := Convert(mdoc, schema)
+			for _, d := range diags {
+				t.Errorf("diagnostic at %d: %s: %s", d.Offset, d.Shortcode, d.Message)
+			}
+			if again := tomarkdoc.Convert(back, schema).Body; again != mdoc {
+				t.Errorf("Markdoc differs after the round trip\n  got : %q\n  want: %q", again, mdoc)
+			}
+
+			shape := func(s string) string {
+				return shortcodeRe.ReplaceAllStringFunc(s, func(m string) string { return m[:3] })
+			}
+			if shape(back) != shape(orig) {
+				t.Errorf("Hugo differs outside shortcode tags\n  got : %q\n  want: %q", shape(back), shape(orig))
+			}
+		})
+	}
+}
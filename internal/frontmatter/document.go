@@ -0,0 +1,606 @@
+package frontmatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the syntax of a front matter block.
+type Format string
+
+const (
+	YAML Format = "yaml"
+	TOML Format = "toml"
+	JSON Format = "json"
+)
+
+// ErrNotFound is returned by Set for a path that is not an editable string.
+var ErrNotFound = errors.New("front matter value not found")
+
+// Document is a front matter block exactly as it appeared in the content
+// file, delimiters included. Set replaces single string values in place, so
+// key order, comments, quoting and the original syntax all survive a round
+// trip and an unchanged document writes back byte-for-byte.
+type Document struct {
+	Format Format
+	raw    []byte
+	values []located
+	edits  map[int]string // index into values -> new text
+}
+
+// located is a string value found in the raw block. start and end cover the
+// value as written, quotes included.
+type located struct {
+	path       []string
+	start, end int
+	text       string // decoded value
+	style      string // how the value is quoted, see encode
+	flow       bool   // YAML: inside a [...] or {...} collection
+	indent     int    // YAML block scalars: indentation of the content
+}
+
+// Parse reads a front matter block, delimiters included, as split off the
+// front of a content file. An empty or whitespace-only block gives an empty
+// Document that writes back unchanged.
+func Parse(block []byte) (*Document, error) {
+	d := &Document{raw: block, edits: map[int]string{}}
+	trimmed := bytes.TrimLeft(block, "\ufeff \t\r\n")
+	var err error
+	switch {
+	case len(trimmed) == 0:
+		return d, nil
+	case bytes.HasPrefix(trimmed, []byte("---")):
+		d.Format = YAML
+		err = d.locateYAML()
+	case bytes.HasPrefix(trimmed, []byte("+++")):
+		d.Format = TOML
+		err = d.locateTOML()
+	case trimmed[0] == '{':
+		d.Format = JSON
+		err = d.locateJSON()
+	default:
+		return nil, fmt.Errorf("unsupported front matter format")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s front matter: %w", d.Format, err)
+	}
+	return d, nil
+}
+
+// Set replaces the string value at path (as produced by Extract) with text.
+// Path segments match case-insensitively.
+func (d *Document) Set(path, text string) error {
+	parts := strings.Split(path, ".")
+	for i, v := range d.values {
+		if !pathEqual(v.path, parts) {
+			continue
+		}
+		if text == v.text {
+			delete(d.edits, i)
+		} else {
+			d.edits[i] = text
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrNotFound, path)
+}
+
+// Bytes renders the document with all edits applied.
+func (d *Document) Bytes() []byte {
+	if len(d.edits) == 0 {
+		return append([]byte(nil), d.raw...)
+	}
+	idx := make([]int, 0, len(d.edits))
+	for i := range d.edits {
+		idx = append(idx, i)
+	}
+	sort.Slice(idx, func(a, b int) bool { return d.values[idx[a]].start > d.values[idx[b]].start })
+
+	out := append([]byte(nil), d.raw...)
+	for _, i := range idx {
+		v := d.values[i]
+		enc := d.encode(v, d.edits[i])
+		out = append(out[:v.start], append([]byte(enc), out[v.end:]...)...)
+	}
+	return out
+}
+
+// Content returns the block, with all edits applied, without its
+// delimiters: the YAML or TOML between the delimiter lines, or the whole
+// JSON object.
+func (d *Document) Content() ([]byte, error) {
+	raw := d.Bytes()
+	var delim string
+	switch d.Format {
+	case YAML:
+		delim = "---"
+	case TOML:
+		delim = "+++"
+	default:
+		return raw, nil
+	}
+	start, end, err := (&Document{raw: raw}).inner(delim)
+	if err != nil {
+		return nil, err
+	}
+	return raw[start:end], nil
+}
+
+func pathEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// inner returns the offsets of the content between the opening delimiter
+// line and the closing delimiter line.
+func (d *Document) inner(delim string) (int, int, error) {
+	open := bytes.Index(d.raw, []byte(delim))
+	nl := bytes.IndexByte(d.raw[open:], '\n')
+	if nl < 0 {
+		return 0, 0, fmt.Errorf("unterminated block")
+	}
+	start := open + nl + 1
+	end := bytes.LastIndex(d.raw, []byte("\n"+delim))
+	if end < start-1 {
+		return 0, 0, fmt.Errorf("unterminated block")
+	}
+	if end < start {
+		end = start // empty block
+	} else {
+		end++ // keep the newline before the closing delimiter
+	}
+	return start, end, nil
+}
+
+/* --------------------------------- YAML ---------------------------------- */
+
+func (d *Document) locateYAML() error {
+	start, end, err := d.inner("---")
+	if err != nil {
+		return err
+	}
+	src := d.raw[start:end]
+	var root yaml.Node
+	if err := yaml.Unmarshal(src, &root); err != nil {
+		return err
+	}
+	lines := lineStarts(src)
+	var walk func(n *yaml.Node, path []string, flow bool)
+	walk = func(n *yaml.Node, path []string, flow bool) {
+		switch n.Kind {
+		case yaml.DocumentNode:
+			for _, c := range n.Content {
+				walk(c, path, flow)
+			}
+		case yaml.MappingNode:
+			flow = flow || n.Style&yaml.FlowStyle != 0
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				walk(n.Content[i+1], appendPath(path, n.Content[i].Value), flow)
+			}
+		case yaml.SequenceNode:
+			flow = flow || n.Style&yaml.FlowStyle != 0
+			for i, c := range n.Content {
+				walk(c, appendPath(path, strconv.Itoa(i)), flow)
+			}
+		case yaml.ScalarNode:
+			if n.Tag != "!!str" || n.Line < 1 || n.Line > len(lines) {
+				return
+			}
+			off := lines[n.Line-1] + runeOffset(src[lines[n.Line-1]:], n.Column-1)
+			v, ok := yamlScalar(src, off, n, flow)
+			if !ok {
+				return // a form we cannot edit in place, e.g. multi-line plain
+			}
+			v.path = path
+			v.start += start
+			v.end += start
+			d.values = append(d.values, v)
+		}
+	}
+	walk(&root, nil, false)
+	return nil
+}
+
+// yamlScalar finds the extent of the scalar starting at off.
+func yamlScalar(src []byte, off int, n *yaml.Node, flow bool) (located, bool) {
+	v := located{start: off, text: n.Value, flow: flow}
+	switch {
+	case n.Style&yaml.DoubleQuotedStyle != 0:
+		v.style = `"`
+		v.end = scanQuoted(src, off, '"', '\\')
+	case n.Style&yaml.SingleQuotedStyle != 0:
+		v.style = `'`
+		v.end = scanQuoted(src, off, '\'', '\'')
+	case n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		v.style = string(src[off])
+		v.end, v.indent = blockScalarEnd(src, off)
+		if v.indent == 0 {
+			return v, false
+		}
+	default:
+		v.style = "plain"
+		end := off
+		for end < len(src) && src[end] != '\n' {
+			if flow && strings.IndexByte(",]}", src[end]) >= 0 {
+				break
+			}
+			if src[end] == '#' && end > off && (src[end-1] == ' ' || src[end-1] == '\t') {
+				break
+			}
+			end++
+		}
+		for end > off && (src[end-1] == ' ' || src[end-1] == '\t' || src[end-1] == '\r') {
+			end--
+		}
+		v.end = end
+		if string(src[off:end]) != n.Value {
+			return v, false
+		}
+	}
+	return v, v.end > v.start
+}
+
+// scanQuoted returns the offset just past the closing quote of the quoted
+// string at off. esc is the escape byte: a backslash for YAML double quotes,
+// or the quote itself for YAML single quotes ('it”s').
+func scanQuoted(src []byte, off int, quote, esc byte) int {
+	for i := off + 1; i < len(src); i++ {
+		if esc == quote && src[i] == quote && i+1 < len(src) && src[i+1] == quote {
+			i++
+			continue
+		}
+		if esc != quote && src[i] == esc {
+			i++
+			continue
+		}
+		if src[i] == quote {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// blockScalarEnd finds the end of a | or > block scalar whose indicator is at
+// off, not counting trailing blank lines, and the content indentation.
+func blockScalarEnd(src []byte, off int) (end, indent int) {
+	nl := bytes.IndexByte(src[off:], '\n')
+	if nl < 0 {
+		return len(src), 0
+	}
+	end = off + nl
+	pos := end + 1
+	for pos < len(src) {
+		lineEnd := bytes.IndexByte(src[pos:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(src) - pos
+		}
+		line := src[pos : pos+lineEnd]
+		if len(bytes.TrimSpace(line)) > 0 {
+			ind := len(line) - len(bytes.TrimLeft(line, " "))
+			if indent == 0 {
+				indent = ind
+			}
+			if ind < indent || ind == 0 {
+				break
+			}
+			end = pos + lineEnd
+		}
+		pos += lineEnd + 1
+	}
+	return end, indent
+}
+
+/* --------------------------------- TOML ---------------------------------- */
+
+func (d *Document) locateTOML() error {
+	start, end, err := d.inner("+++")
+	if err != nil {
+		return err
+	}
+	src := d.raw[start:end]
+
+	var p unstable.Parser
+	p.Reset(src)
+	var table []string
+	arrays := map[string]int{} // array table path -> index of the current item
+
+	// tablePath resolves a [table] or [[array]] header key, stepping into
+	// the current item of any array tables along the way.
+	tablePath := func(key []string, isArray bool) []string {
+		var path []string
+		for i, k := range key {
+			path = appendPath(path, k)
+			id := strings.Join(key[:i+1], "\x00")
+			if isArray && i == len(key)-1 {
+				if _, seen := arrays[id]; seen {
+					arrays[id]++
+				} else {
+					arrays[id] = 0
+				}
+				// A new item starts its own nested array tables
+				for k := range arrays {
+					if strings.HasPrefix(k, id+"\x00") {
+						delete(arrays, k)
+					}
+				}
+			}
+			if n, ok := arrays[id]; ok {
+				path = appendPath(path, strconv.Itoa(n))
+			}
+		}
+		return path
+	}
+
+	var walk func(n *unstable.Node, path []string)
+	walk = func(n *unstable.Node, path []string) {
+		switch n.Kind {
+		case unstable.String:
+			raw := p.Raw(n.Raw)
+			style := string(raw[0])
+			if len(raw) >= 6 && (bytes.HasPrefix(raw, []byte(`"""`)) || bytes.HasPrefix(raw, []byte("'''"))) {
+				style = string(raw[:3])
+			}
+			d.values = append(d.values, located{
+				path:  path,
+				start: start + int(n.Raw.Offset),
+				end:   start + int(n.Raw.Offset) + int(n.Raw.Length),
+				text:  string(n.Data),
+				style: style,
+			})
+		case unstable.Array:
+			i := 0
+			for it := n.Children(); it.Next(); i++ {
+				walk(it.Node(), appendPath(path, strconv.Itoa(i)))
+			}
+		case unstable.InlineTable:
+			for it := n.Children(); it.Next(); {
+				kv := it.Node()
+				walk(kv.Value(), append(append([]string(nil), path...), tomlKey(kv)...))
+			}
+		}
+	}
+
+	for p.NextExpression() {
+		e := p.Expression()
+		switch e.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			table = tablePath(tomlKey(e), e.Kind == unstable.ArrayTable)
+		case unstable.KeyValue:
+			walk(e.Value(), append(append([]string(nil), table...), tomlKey(e)...))
+		}
+	}
+	return p.Error()
+}
+
+func tomlKey(n *unstable.Node) []string {
+	var key []string
+	for it := n.Key(); it.Next(); {
+		key = append(key, string(it.Node().Data))
+	}
+	return key
+}
+
+/* --------------------------------- JSON ---------------------------------- */
+
+func (d *Document) locateJSON() error {
+	open := bytes.IndexByte(d.raw, '{')
+	src := d.raw[open:]
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.UseNumber()
+
+	type frame struct {
+		path      []string
+		object    bool
+		key       string
+		expectKey bool
+		index     int
+	}
+	var stack []*frame
+	// childPath is the path of the next value in the innermost container.
+	childPath := func() []string {
+		if len(stack) == 0 {
+			return nil
+		}
+		f := stack[len(stack)-1]
+		if f.object {
+			return appendPath(f.path, f.key)
+		}
+		return appendPath(f.path, strconv.Itoa(f.index))
+	}
+	// advance moves past a value in the innermost container.
+	advance := func() {
+		if len(stack) == 0 {
+			return
+		}
+		f := stack[len(stack)-1]
+		if f.object {
+			f.expectKey = true
+		} else {
+			f.index++
+		}
+	}
+
+	for {
+		before := int(dec.InputOffset())
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{', '[':
+				stack = append(stack, &frame{path: childPath(), object: t == '{', expectKey: t == '{'})
+			case '}', ']':
+				stack = stack[:len(stack)-1]
+				advance()
+				if len(stack) == 0 {
+					return nil // done; the rest of the block is content
+				}
+			}
+		case string:
+			f := stack[len(stack)-1]
+			if f.object && f.expectKey {
+				f.key = t
+				f.expectKey = false
+				continue
+			}
+			q := before + bytes.IndexByte(src[before:], '"')
+			d.values = append(d.values, located{
+				path:  childPath(),
+				start: open + q,
+				end:   open + int(dec.InputOffset()),
+				text:  t,
+				style: `"`,
+			})
+			advance()
+		default:
+			advance()
+		}
+	}
+	return nil
+}
+
+/* ------------------------------- Encoding -------------------------------- */
+
+// encode writes text in the style v had in the source, switching to a more
+// capable quoting only when text cannot be written that way.
+func (d *Document) encode(v located, text string) string {
+	switch d.Format {
+	case JSON:
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		_ = enc.Encode(text)
+		return strings.TrimSuffix(buf.String(), "\n")
+	case TOML:
+		return encodeTOML(v.style, text)
+	}
+
+	switch v.style {
+	case "plain":
+		if b, err := yaml.Marshal(text); err == nil && strings.TrimSuffix(string(b), "\n") == text &&
+			!(v.flow && strings.ContainsAny(text, ",[]{}")) {
+			return text
+		}
+	case `'`:
+		if !strings.Contains(text, "\n") {
+			return `'` + strings.ReplaceAll(text, `'`, `''`) + `'`
+		}
+	case "|", ">":
+		body := strings.TrimSuffix(text, "\n")
+		if !strings.HasSuffix(body, "\n") && (v.style == "|" || !strings.Contains(body, "\n")) {
+			header := v.style
+			if body == text {
+				header += "-"
+			}
+			pad := strings.Repeat(" ", v.indent)
+			var b strings.Builder
+			b.WriteString(header)
+			for _, line := range strings.Split(body, "\n") {
+				b.WriteString("\n")
+				if line != "" {
+					b.WriteString(pad + line)
+				}
+			}
+			return b.String()
+		}
+	}
+	return strconv.Quote(text)
+}
+
+// encodeTOML writes text as a TOML string, keeping the original kind of
+// string where text allows it.
+func encodeTOML(style, text string) string {
+	if len(style) == 3 && strings.HasPrefix(text, "\n") {
+		// A newline right after the opening delimiter is trimmed on parse
+		text = "\n" + text
+	}
+	switch style {
+	case "'":
+		if !strings.ContainsAny(text, "'\n\r") {
+			return "'" + text + "'"
+		}
+	case "'''":
+		if !strings.Contains(text, "'''") && !strings.HasSuffix(text, "'") {
+			return "'''" + text + "'''"
+		}
+	case `"""`:
+		return `"""` + tomlEscape(text, true) + `"""`
+	}
+	return `"` + tomlEscape(text, false) + `"`
+}
+
+// tomlEscape escapes text for a basic string. Multi-line basic strings may
+// keep their newlines as they are.
+func tomlEscape(text string, multiline bool) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n' && multiline:
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+/* -------------------------------- Helpers -------------------------------- */
+
+func appendPath(path []string, seg string) []string {
+	out := make([]string, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, seg)
+}
+
+func lineStarts(src []byte) []int {
+	starts := []int{0}
+	for i, c := range src {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// runeOffset returns the byte offset of the n-th rune in b.
+func runeOffset(b []byte, n int) int {
+	off := 0
+	for i := 0; i < n && off < len(b); i++ {
+		_, size := utf8.DecodeRune(b[off:])
+		off += size
+	}
+	return off
+}
@@ -0,0 +1,252 @@
+package frontmatter
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gohugoio/hugo/parser/metadecoders"
+)
+
+// decode parses a rendered block the way Hugo does.
+func decode(t *testing.T, block string, f Format) map[string]any {
+	t.Helper()
+	inner := block
+	switch f {
+	case YAML, TOML:
+		delim := "---"
+		if f == TOML {
+			delim = "+++"
+		}
+		inner = strings.TrimPrefix(inner, delim+"\n")
+		inner = inner[:strings.LastIndex(inner, delim)]
+	}
+	m, err := metadecoders.Default.UnmarshalToMap([]byte(inner), metadecoders.Format(f))
+	if err != nil {
+		t.Fatalf("rendered front matter does not parse: %v\n%s", err, block)
+	}
+	return m
+}
+
+func TestDocument_Unchanged(t *testing.T) {
+	t.Parallel()
+
+	for _, block := range []string{
+		"---\n# comment\ntitle: \"Simple File\"\ntags: [\"demo\", \"parser\"]\ndraft: false\n---\n",
+		"+++\ntitle = 'T' # comment\n[menu.main]\nname = \"Home\"\n+++\n",
+		"{\n  \"title\": \"T\",\n  \"tags\": [\"a\"]\n}\n",
+		"",
+	} {
+		d, err := Parse([]byte(block))
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", block, err)
+		}
+		if got := string(d.Bytes()); got != block {
+			t.Errorf("round trip changed the block:\n got: %q\nwant: %q", got, block)
+		}
+	}
+}
+
+func TestDocument_YAML(t *testing.T) {
+	t.Parallel()
+
+	block := `---
+# Page settings
+title: "Everything Bagel: Complex Conversion Test"
+summary: 'It''s plain'
+description: Plain text # trailing comment
+tags: ["demo", shortcodes]
+menu:
+  main:
+    name: Home
+    weight: 10
+notes: |
+  First line
+  Second line
+draft: false
+---
+`
+	d, err := Parse([]byte(block))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, text := range map[string]string{
+		"title":          "Everythingway Agelbay",
+		"summary":        "Isn't plain",
+		"description":    "Needs: quoting",
+		"tags.0":         "emoday",
+		"tags.1":         "a, b",
+		"menu.main.name": "Omehay",
+		"notes":          "Irstfay\nEcondsay\n",
+	} {
+		if err := d.Set(path, text); err != nil {
+			t.Fatalf("Set(%s): %v", path, err)
+		}
+	}
+
+	want := `---
+# Page settings
+title: "Everythingway Agelbay"
+summary: 'Isn''t plain'
+description: "Needs: quoting" # trailing comment
+tags: ["emoday", "a, b"]
+menu:
+  main:
+    name: Omehay
+    weight: 10
+notes: |
+  Irstfay
+  Econdsay
+draft: false
+---
+`
+	got := string(d.Bytes())
+	if got != want {
+		t.Fatalf("YAML edit mismatch:\n--- got ---\n%s\n--- want ---\n%s", got, want)
+	}
+	m := decode(t, got, YAML)
+	if m["notes"] != "Irstfay\nEcondsay\n" || m["description"] != "Needs: quoting" {
+		t.Errorf("decoded values wrong: %v", m)
+	}
+}
+
+func TestDocument_TOML(t *testing.T) {
+	t.Parallel()
+
+	block := `+++
+title = "Hello" # comment
+summary = 'Literal'
+tags = ["a", 'b']
+[menu.main]
+name = "Home"
+[[resources]]
+title = "One"
+[[resources]]
+title = "Two"
+params = { caption = "Cap" }
++++
+`
+	d, err := Parse([]byte(block))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, text := range map[string]string{
+		"title":                      `Say "hi"`,
+		"summary":                    "Iteral'lay",
+		"tags.1":                     "bay",
+		"menu.main.name":             "Omehay",
+		"resources.1.title":          "Otway",
+		"resources.1.params.caption": "Apcay",
+	} {
+		if err := d.Set(path, text); err != nil {
+			t.Fatalf("Set(%s): %v", path, err)
+		}
+	}
+
+	want := `+++
+title = "Say \"hi\"" # comment
+summary = "Iteral'lay"
+tags = ["a", 'bay']
+[menu.main]
+name = "Omehay"
+[[resources]]
+title = "One"
+[[resources]]
+title = "Otway"
+params = { caption = "Apcay" }
++++
+`
+	got := string(d.Bytes())
+	if got != want {
+		t.Fatalf("TOML edit mismatch:\n--- got ---\n%s\n--- want ---\n%s", got, want)
+	}
+	decode(t, got, TOML)
+}
+
+func TestDocument_TOMLNestedArrayTables(t *testing.T) {
+	t.Parallel()
+
+	block := "+++\n[[a]]\n[[a.b]]\nx = \"1\"\n[[a]]\n[[a.b]]\nx = \"2\"\n+++\n"
+	d, err := Parse([]byte(block))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("a.0.b.0.x", "one"); err != nil {
+		t.Fatalf("Set(a.0.b.0.x): %v", err)
+	}
+	if err := d.Set("a.1.b.0.x", "two"); err != nil {
+		t.Fatalf("Set(a.1.b.0.x): %v", err)
+	}
+	want := "+++\n[[a]]\n[[a.b]]\nx = \"one\"\n[[a]]\n[[a.b]]\nx = \"two\"\n+++\n"
+	if got := string(d.Bytes()); got != want {
+		t.Fatalf("TOML edit mismatch:\n got: %q\nwant: %q", got, want)
+	}
+	decode(t, want, TOML)
+}
+
+func TestDocument_JSON(t *testing.T) {
+	t.Parallel()
+
+	block := "{\n  \"title\": \"Hello\",\n  \"weight\": 3,\n  \"tags\": [\"a\", \"b\"],\n  \"menu\": {\"main\": {\"name\": \"Home\"}}\n}\n"
+	d, err := Parse([]byte(block))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("tags.1", "<bay>"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("menu.main.name", "Omehay"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "{\n  \"title\": \"Hello\",\n  \"weight\": 3,\n  \"tags\": [\"a\", \"<bay>\"],\n  \"menu\": {\"main\": {\"name\": \"Omehay\"}}\n}\n"
+	got := string(d.Bytes())
+	if got != want {
+		t.Fatalf("JSON edit mismatch:\n got: %q\nwant: %q", got, want)
+	}
+	m := decode(t, got, JSON)
+	if !reflect.DeepEqual(m["tags"], []any{"a", "<bay>"}) {
+		t.Errorf("decoded tags = %v", m["tags"])
+	}
+}
+
+func TestDocument_SetErrors(t *testing.T) {
+	t.Parallel()
+
+	d, err := Parse([]byte("---\ntitle: T\ndraft: false\n---\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"draft", "missing", "title.x"} {
+		if err := d.Set(path, "x"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Set(%s) = %v, want ErrNotFound", path, err)
+		}
+	}
+	// Setting a value back to the original drops the edit
+	_ = d.Set("title", "New")
+	_ = d.Set("title", "T")
+	if got := string(d.Bytes()); got != "---\ntitle: T\ndraft: false\n---\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestDocument_Content(t *testing.T) {
+	t.Parallel()
+
+	for block, want := range map[string]string{
+		"---\ntitle: T\n---\n":    "title: T\n",
+		"+++\ntitle = 'T'\n+++\n": "title = 'T'\n",
+		"{\"title\": \"T\"}\n":    "{\"title\": \"T\"}\n",
+		"---\n---\n":              "",
+	} {
+		d, err := Parse([]byte(block))
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", block, err)
+		}
+		got, err := d.Content()
+		if err != nil || string(got) != want {
+			t.Errorf("Content(%q) = %q, %v; want %q", block, got, err, want)
+		}
+	}
+}
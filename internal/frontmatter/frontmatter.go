@@ -0,0 +1,63 @@
+package frontmatter
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Field is a translatable string value in the front matter.
+// Path is dot-separated, with list items addressed by index,
+// e.g. "title", "tags.1" or "menu.main.name".
+type Field struct {
+	Path string `json:"path"`
+	Text string `json:"text"`
+}
+
+// Extract returns the string values selected by keys, in key order.
+// A key is a dot-separated path; "*" matches any map key. When a path
+// ends on a list, every string item is selected, and lists in the middle
+// of a path are stepped through item by item. Keys match case-insensitively,
+// like Hugo's own front matter lookups.
+func Extract(fm map[string]any, keys []string) []Field {
+	var out []Field
+	for _, k := range keys {
+		if k == "" {
+			continue
+		}
+		out = collect(out, fm, strings.Split(k, "."), nil)
+	}
+	return out
+}
+
+func collect(out []Field, v any, parts []string, path []string) []Field {
+	switch t := v.(type) {
+	case string:
+		if len(parts) == 0 {
+			out = append(out, Field{Path: strings.Join(path, "."), Text: t})
+		}
+	case []any:
+		for i, item := range t {
+			out = collect(out, item, parts, append(path, strconv.Itoa(i)))
+		}
+	case map[string]any:
+		if len(parts) == 0 {
+			return out
+		}
+		for _, k := range sortedKeys(t) {
+			if parts[0] == "*" || strings.EqualFold(parts[0], k) {
+				out = collect(out, t[k], parts[1:], append(path, k))
+			}
+		}
+	}
+	return out
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
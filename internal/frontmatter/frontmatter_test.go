@@ -0,0 +1,52 @@
+package frontmatter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func sample() map[string]any {
+	return map[string]any{
+		"title": "Simple File",
+		"draft": false,
+		"tags":  []any{"demo", "parser"},
+		"menu": map[string]any{
+			"main": map[string]any{"name": "Home", "weight": 10},
+		},
+		"resources": []any{
+			map[string]any{"src": "a.png", "title": "First"},
+			map[string]any{"src": "b.png", "title": "Second"},
+		},
+	}
+}
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		keys []string
+		want []Field
+	}{
+		{"scalar", []string{"title"}, []Field{{"title", "Simple File"}}},
+		{"case-insensitive", []string{"Title"}, []Field{{"title", "Simple File"}}},
+		{"list", []string{"tags"}, []Field{{"tags.0", "demo"}, {"tags.1", "parser"}}},
+		{"nested", []string{"menu.main.name"}, []Field{{"menu.main.name", "Home"}}},
+		{"wildcard", []string{"menu.*.name"}, []Field{{"menu.main.name", "Home"}}},
+		{"list of maps", []string{"resources.title"}, []Field{{"resources.0.title", "First"}, {"resources.1.title", "Second"}}},
+		{"non-string ignored", []string{"draft", "menu.main.weight"}, nil},
+		{"map not selected whole", []string{"menu"}, nil},
+		{"missing", []string{"summary"}, nil},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := Extract(sample(), tc.keys)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Extract(%v)\n  got : %v\n  want: %v", tc.keys, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package hugoconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gohugoio/hugo/parser/metadecoders"
+)
+
+// Names lists the site config files Hugo looks for, in its order.
+var Names = []string{
+	"hugo.toml", "hugo.yaml", "hugo.yml", "hugo.json",
+	"config.toml", "config.yaml", "config.yml", "config.json",
+}
+
+// Language is one entry of the site's `languages` table.
+type Language struct {
+	Code       string
+	ContentDir string // "" when the language has no contentDir of its own
+	Weight     int
+}
+
+// Site is the multilingual part of a Hugo site config, with its baseURL.
+type Site struct {
+	BaseURL         string
+	DefaultLanguage string     // defaultContentLanguage, "en" when unset
+	ContentDir      string     // contentDir, "content" when unset
+	Languages       []Language // sorted by weight, then code
+}
+
+// Find returns the first site config file in dir.
+func Find(dir string) (string, bool) {
+	for _, name := range Names {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// Load reads the multilingual settings of a site config. The format follows
+// the file extension.
+func Load(path string) (Site, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Site{}, err
+	}
+	format := metadecoders.FormatFromString(strings.TrimPrefix(filepath.Ext(path), "."))
+	if format == "" {
+		return Site{}, fmt.Errorf("%s: unknown config format", path)
+	}
+	m, err := metadecoders.Default.UnmarshalToMap(b, format)
+	if err != nil {
+		return Site{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	site := Site{DefaultLanguage: "en", ContentDir: "content"}
+	// Hugo keys are case-insensitive
+	for k, v := range m {
+		switch strings.ToLower(k) {
+		case "baseurl":
+			site.BaseURL, _ = v.(string)
+		case "defaultcontentlanguage":
+			if s, ok := v.(string); ok && s != "" {
+				site.DefaultLanguage = s
+			}
+		case "contentdir":
+			if s, ok := v.(string); ok && s != "" {
+				site.ContentDir = s
+			}
+		case "languages":
+			langs, ok := v.(map[string]any)
+			if !ok {
+				return Site{}, fmt.Errorf("%s: languages is not a table", path)
+			}
+			for code, lv := range langs {
+				lang := Language{Code: code}
+				if lm, ok := lv.(map[string]any); ok {
+					for lk, lv := range lm {
+						switch strings.ToLower(lk) {
+						case "contentdir":
+							lang.ContentDir, _ = lv.(string)
+						case "weight":
+							lang.Weight = toInt(lv)
+						}
+					}
+				}
+				site.Languages = append(site.Languages, lang)
+			}
+		}
+	}
+	sort.Slice(site.Languages, func(i, j int) bool {
+		a, b := site.Languages[i], site.Languages[j]
+		if a.Weight != b.Weight {
+			return a.Weight < b.Weight
+		}
+		return a.Code < b.Code
+	})
+	return site, nil
+}
+
+// ErrNoLanguages is returned by TargetLocales for a site without languages.
+var ErrNoLanguages = errors.New("site config has no languages besides the default")
+
+// TargetLocales returns the site's languages other than the default one.
+func (s Site) TargetLocales() ([]string, error) {
+	var out []string
+	for _, l := range s.Languages {
+		if !strings.EqualFold(l.Code, s.DefaultLanguage) {
+			out = append(out, l.Code)
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrNoLanguages
+	}
+	return out, nil
+}
+
+// ContentDirFor returns the contentDir configured for a language, or "".
+func (s Site) ContentDirFor(code string) string {
+	for _, l := range s.Languages {
+		if strings.EqualFold(l.Code, code) {
+			return l.ContentDir
+		}
+	}
+	return ""
+}
+
+func toInt(v any) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case uint64:
+		return int(n)
+	case float64:
+		return int(n)
+	}
+	return 0
+}
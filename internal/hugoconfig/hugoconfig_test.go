@@ -0,0 +1,75 @@
+package hugoconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name, file, body string
+		want             Site
+		targets          []string
+	}{
+		{
+			name: "toml",
+			file: "hugo.toml",
+			body: "baseURL = 'https://example.org/'\ndefaultContentLanguage = 'de'\n[languages.de]\nweight = 1\n[languages.ja]\nweight = 3\ncontentDir = 'content/ja'\n[languages.fr]\nweight = 2\n",
+			want: Site{BaseURL: "https://example.org/", DefaultLanguage: "de", ContentDir: "content", Languages: []Language{
+				{Code: "de", Weight: 1}, {Code: "fr", Weight: 2}, {Code: "ja", ContentDir: "content/ja", Weight: 3},
+			}},
+			targets: []string{"fr", "ja"},
+		},
+		{
+			name: "yaml, default language implied",
+			file: "config.yaml",
+			body: "contentDir: src\nlanguages:\n  en: {}\n  es:\n    languageName: Español\n",
+			want: Site{DefaultLanguage: "en", ContentDir: "src", Languages: []Language{
+				{Code: "en"}, {Code: "es"},
+			}},
+			targets: []string{"es"},
+		},
+		{
+			name: "no languages",
+			file: "hugo.json",
+			body: `{"title": "Site"}`,
+			want: Site{DefaultLanguage: "en", ContentDir: "content"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, tc.file), []byte(tc.body), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			path, ok := Find(dir)
+			if !ok || filepath.Base(path) != tc.file {
+				t.Fatalf("Find = %q, %v", path, ok)
+			}
+			site, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(site, tc.want) {
+				t.Fatalf("Load\n  got : %+v\n  want: %+v", site, tc.want)
+			}
+			targets, err := site.TargetLocales()
+			if tc.targets == nil {
+				if !errors.Is(err, ErrNoLanguages) {
+					t.Fatalf("TargetLocales err = %v, want ErrNoLanguages", err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(targets, tc.targets) {
+				t.Fatalf("TargetLocales = %v, %v; want %v", targets, err, tc.targets)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// Manifest records what each output was built from, so that a later run can
+// skip unchanged sources and reuse the translations of unchanged segments.
+type Manifest struct {
+	// Settings identifies everything besides the sources that shapes the
+	// outputs: translator, locales and the translation config. Entries built
+	// with other settings are not reused.
+	Settings string          `json:"settings"`
+	Files    map[string]File `json:"files"` // keyed by source path
+}
+
+// File is the manifest entry of one source file.
+type File struct {
+	SourceHash string                       `json:"sourceHash"`
+	Outputs    []string                     `json:"outputs"`
+	Segments   map[string]map[string]string `json:"segments,omitempty"` // locale -> segment hash -> translation
+}
+
+// New returns an empty manifest for settings.
+func New(settings string) *Manifest {
+	return &Manifest{Settings: settings, Files: map[string]File{}}
+}
+
+// Hash returns the hex SHA-256 of b.
+func Hash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Load reads the manifest at path. A missing file, or one written with other
+// settings, gives an empty manifest.
+func Load(path, settings string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return New(settings), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if m.Settings != settings || m.Files == nil {
+		return New(settings), nil
+	}
+	return &m, nil
+}
+
+// Save writes the manifest as indented JSON.
+func (m *Manifest) Save(path string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// Unchanged reports whether source was built from a file with this hash and
+// all of its outputs still exist.
+func (m *Manifest) Unchanged(source, hash string) bool {
+	f, ok := m.Files[source]
+	if !ok || f.SourceHash != hash {
+		return false
+	}
+	for _, out := range f.Outputs {
+		if _, err := os.Stat(out); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Sources returns the recorded source paths, sorted.
+func (m *Manifest) Sources() []string {
+	out := make([]string, 0, len(m.Files))
+	for s := range m.Files {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
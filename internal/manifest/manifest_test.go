@@ -0,0 +1,66 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadSave(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "manifest.json")
+	out := filepath.Join(dir, "translated.md")
+	if err := os.WriteFile(out, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Missing file: empty manifest
+	m, err := Load(path, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 0 {
+		t.Fatalf("Files = %v", m.Files)
+	}
+
+	hash := Hash([]byte("source"))
+	m.Files["content/a.md"] = File{SourceHash: hash, Outputs: []string{out}, Segments: map[string]map[string]string{"x-piglatin": {Hash([]byte("Hello")): "Ellohay"}}}
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	m2, err := Load(path, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, m2) {
+		t.Fatalf("round trip\n  got : %+v\n  want: %+v", m2, m)
+	}
+	if !m2.Unchanged("content/a.md", hash) {
+		t.Error("Unchanged = false for the same hash")
+	}
+	if m2.Unchanged("content/a.md", Hash([]byte("edited"))) {
+		t.Error("Unchanged = true for an edited source")
+	}
+	if m2.Unchanged("content/b.md", hash) {
+		t.Error("Unchanged = true for an unknown source")
+	}
+	if err := os.Remove(out); err != nil {
+		t.Fatal(err)
+	}
+	if m2.Unchanged("content/a.md", hash) {
+		t.Error("Unchanged = true with a missing output")
+	}
+
+	// Other settings: nothing is reused
+	m3, err := Load(path, "s2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m3.Files) != 0 || m3.Settings != "s2" {
+		t.Errorf("Load with other settings = %+v", m3)
+	}
+}
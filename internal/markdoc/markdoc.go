@@ -0,0 +1,268 @@
+package markdoc
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"hugotranslationstudy/internal/subtokenize"
+)
+
+// Tag is one Markdoc tag: {% name attrs %}, {% name attrs /%} or {% /name %}.
+// A tag without a name, such as {% .class %}, is an annotation.
+type Tag struct {
+	Name        string
+	Attributes  []Attribute
+	Closing     bool // {% /name %}
+	SelfClosing bool // {% name /%}
+	Start, End  int  // byte offsets of "{%" and just past "%}"
+}
+
+// Attribute is a tag attribute. Name is empty for the primary attribute, a
+// bare value right after the tag name. Value is a string, bool, int,
+// float64 or nil.
+type Attribute struct {
+	Name  string
+	Value any
+}
+
+// Attr returns the value of the named attribute.
+func (t Tag) Attr(name string) (any, bool) {
+	for _, a := range t.Attributes {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Violation is a problem found in a Markdoc document, at a 1-based line and
+// column (in characters).
+type Violation struct {
+	Offset    int
+	Line, Col int
+	Message   string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%d:%d: %s", v.Line, v.Col, v.Message)
+}
+
+var (
+	nameRe   = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*`)
+	numberRe = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?`)
+)
+
+// Parse finds the tags of a Markdoc document, in order. Tags inside code
+// blocks and code spans, and front matter, are skipped. Tags that cannot be
+// parsed are reported and left out.
+func Parse(src []byte) ([]Tag, []Violation) {
+	start := frontMatterEnd(src)
+	body := src[start:]
+
+	var code []subtokenize.Range
+	for _, r := range subtokenize.CodeRanges(body) {
+		code = append(code, subtokenize.Range{Start: r.Start + start, Stop: r.Stop + start})
+	}
+	inCode := func(pos int) bool {
+		for _, r := range code {
+			if r.Start <= pos && pos < r.Stop {
+				return true
+			}
+		}
+		return false
+	}
+
+	var tags []Tag
+	var errs []Violation
+	for pos := start; ; {
+		i := bytes.Index(src[pos:], []byte("{%"))
+		if i < 0 {
+			break
+		}
+		open := pos + i
+		if inCode(open) {
+			pos = open + 2
+			continue
+		}
+		end := tagEnd(src, open+2)
+		if end < 0 {
+			errs = append(errs, violation(src, open, "unterminated tag"))
+			break
+		}
+		tag, err := parseTag(string(src[open+2 : end-2]))
+		if err != nil {
+			errs = append(errs, violation(src, open, err.Error()))
+		} else {
+			tag.Start, tag.End = open, end
+			tags = append(tags, tag)
+		}
+		pos = end
+	}
+	return tags, errs
+}
+
+// tagEnd returns the offset just past the "%}" that ends the tag whose
+// inside starts at pos, skipping quoted strings, or -1.
+func tagEnd(src []byte, pos int) int {
+	quoted := false
+	for i := pos; i < len(src); i++ {
+		switch {
+		case quoted && src[i] == '\\':
+			i++
+		case src[i] == '"':
+			quoted = !quoted
+		case !quoted && src[i] == '%' && i+1 < len(src) && src[i+1] == '}':
+			return i + 2
+		}
+	}
+	return -1
+}
+
+// parseTag parses the inside of a tag, between "{%" and "%}".
+func parseTag(s string) (Tag, error) {
+	var t Tag
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "/") {
+		t.SelfClosing = true
+		s = strings.TrimSpace(strings.TrimSuffix(s, "/"))
+	}
+	if strings.HasPrefix(s, "/") {
+		t.Closing = true
+		s = strings.TrimSpace(s[1:])
+		t.Name = nameRe.FindString(s)
+		switch {
+		case t.SelfClosing:
+			return t, fmt.Errorf("closing tag %q cannot be self-closing", t.Name)
+		case t.Name == "" || t.Name != s:
+			return t, fmt.Errorf("malformed closing tag %q", s)
+		}
+		return t, nil
+	}
+
+	if name := nameRe.FindString(s); name != "" && !strings.HasPrefix(s[len(name):], "=") {
+		t.Name = name
+		s = s[len(name):]
+	}
+	for first := true; ; first = false {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			break
+		}
+		var a Attribute
+		var err error
+		switch {
+		case s[0] == '.' || s[0] == '#':
+			// Shorthand: .class or #id
+			v := nameRe.FindString(s[1:])
+			if v == "" {
+				return t, fmt.Errorf("malformed shorthand %q", s)
+			}
+			a = Attribute{Name: map[byte]string{'.': "class", '#': "id"}[s[0]], Value: v}
+			s = s[1+len(v):]
+		case nameRe.MatchString(s) && strings.HasPrefix(s[len(nameRe.FindString(s)):], "="):
+			a.Name = nameRe.FindString(s)
+			s = s[len(a.Name)+1:]
+			if a.Value, s, err = parseValue(s); err != nil {
+				return t, fmt.Errorf("attribute %s: %w", a.Name, err)
+			}
+		default:
+			if !first || t.Name == "" {
+				return t, fmt.Errorf("unexpected %q", firstField(s))
+			}
+			if a.Value, s, err = parseValue(s); err != nil {
+				return t, fmt.Errorf("primary attribute: %w", err)
+			}
+		}
+		t.Attributes = append(t.Attributes, a)
+	}
+	return t, nil
+}
+
+// parseValue parses an attribute value at the start of s and returns it
+// with the rest of s.
+func parseValue(s string) (any, string, error) {
+	if strings.HasPrefix(s, `"`) {
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch c := s[i]; c {
+			case '"':
+				return b.String(), s[i+1:], nil
+			case '\\':
+				if i+1 == len(s) {
+					return nil, "", fmt.Errorf("unterminated string")
+				}
+				i++
+				switch e := s[i]; e {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(e)
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return nil, "", fmt.Errorf("unterminated string")
+	}
+	if n := numberRe.FindString(s); n != "" && endOfValue(s[len(n):]) {
+		if strings.Contains(n, ".") {
+			f, err := strconv.ParseFloat(n, 64)
+			return f, s[len(n):], err
+		}
+		i, err := strconv.Atoi(n)
+		return i, s[len(n):], err
+	}
+	for lit, v := range map[string]any{"true": true, "false": false, "null": nil} {
+		if strings.HasPrefix(s, lit) && endOfValue(s[len(lit):]) {
+			return v, s[len(lit):], nil
+		}
+	}
+	return nil, "", fmt.Errorf("unsupported value %q", firstField(s))
+}
+
+func endOfValue(rest string) bool {
+	return rest == "" || strings.ContainsAny(rest[:1], " \t\r\n")
+}
+
+func firstField(s string) string {
+	if f := strings.Fields(s); len(f) > 0 {
+		return f[0]
+	}
+	return s
+}
+
+// frontMatterEnd returns the offset just past a leading "---" or "+++"
+// front matter block, or 0.
+func frontMatterEnd(src []byte) int {
+	for _, delim := range []string{"---", "+++"} {
+		if !bytes.HasPrefix(src, []byte(delim+"\n")) && !bytes.HasPrefix(src, []byte(delim+"\r\n")) {
+			continue
+		}
+		lines := bytes.SplitAfter(src, []byte("\n"))
+		pos := len(lines[0])
+		for _, l := range lines[1:] {
+			pos += len(l)
+			if string(bytes.TrimRight(l, "\r\n")) == delim {
+				return pos
+			}
+		}
+	}
+	return 0
+}
+
+// violation locates a problem at offset in src.
+func violation(src []byte, offset int, msg string) Violation {
+	lineStart := bytes.LastIndexByte(src[:offset], '\n') + 1
+	return Violation{
+		Offset:  offset,
+		Line:    1 + bytes.Count(src[:offset], []byte("\n")),
+		Col:     1 + utf8.RuneCount(src[lineStart:offset]),
+		Message: msg,
+	}
+}
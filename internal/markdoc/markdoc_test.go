@@ -0,0 +1,155 @@
+package markdoc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		want []Tag
+		errs []string
+	}{
+		{
+			name: "typed attributes",
+			in:   `{% chart width=640 ratio=1.5 legend=true title="Say \"hi\"" x=null /%}`,
+			want: []Tag{{Name: "chart", SelfClosing: true, Attributes: []Attribute{
+				{"width", 640}, {"ratio", 1.5}, {"legend", true}, {"title", `Say "hi"`}, {"x", nil},
+			}}},
+		},
+		{
+			name: "paired with primary attribute and shorthand",
+			in:   "{% callout \"Hi\" .wide #top %}\nBody\n{% /callout %}",
+			want: []Tag{
+				{Name: "callout", Attributes: []Attribute{{"", "Hi"}, {"class", "wide"}, {"id", "top"}}},
+				{Name: "callout", Closing: true},
+			},
+		},
+		{
+			name: "annotation",
+			in:   "# Title {% .big %}",
+			want: []Tag{{Attributes: []Attribute{{"class", "big"}}}},
+		},
+		{
+			name: "string with %}",
+			in:   `{% note text="50%} off" /%}`,
+			want: []Tag{{Name: "note", SelfClosing: true, Attributes: []Attribute{{"text", "50%} off"}}}},
+		},
+		{
+			name: "code and front matter skipped",
+			in:   "---\nx: \"{% no %}\"\n---\nUse `{% a %}`.\n\n```\n{% b %}\n```\n{% c /%}\n",
+			want: []Tag{{Name: "c", SelfClosing: true}},
+		},
+		{
+			name: "errors",
+			in:   "{% a b %}\n{% /a x %}\n{% c d=\"e %}",
+			errs: []string{`1:1: primary attribute: unsupported value "b"`, `2:1: malformed closing tag "a x"`, "3:1: unterminated tag"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tags, vs := Parse([]byte(tc.in))
+			for i := range tags {
+				tags[i].Start, tags[i].End = 0, 0
+			}
+			if !reflect.DeepEqual(tags, tc.want) {
+				t.Errorf("Parse(%q) tags\n  got : %+v\n  want: %+v", tc.in, tags, tc.want)
+			}
+			if got := strings.Join(messages(vs), "\n"); got != strings.Join(tc.errs, "\n") {
+				t.Errorf("Parse(%q) errors\n  got : %q\n  want: %q", tc.in, messages(vs), tc.errs)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	yes, no := true, false
+	schema := Schema{Tags: map[string]TagSchema{
+		"tabs":  {Children: []string{"tab"}, SelfClosing: &no},
+		"tab":   {Required: []string{"name"}},
+		"badge": {Required: []string{"text"}, Attributes: []string{"text", "color"}, SelfClosing: &yes},
+		"box":   {},
+	}}
+	tests := []struct {
+		name   string
+		in     string
+		schema Schema
+		want   []string
+	}{
+		{
+			name:   "valid",
+			in:     "{% tabs %}\n{% tab name=\"A\" %}\n{% badge text=\"x\" /%}\n{% /tab %}\n{% /tabs %}\n",
+			schema: schema,
+		},
+		{
+			name: "unbalanced",
+			in:   "{% box %}\n{% tab %}x\n{% /box %}\n{% /tab %}\n{% box %}",
+			want: []string{
+				"2:1: tag tab is not closed before /box",
+				"4:1: closing tag tab without an opening tag",
+				"5:1: tag box is never closed",
+			},
+		},
+		{
+			name:   "schema",
+			in:     "{% tabs /%}\n{% tabs %}{% box %}{% /box %}{% /tabs %}\n{% tab %}{% /tab %}\n  {% badge text=\"x\" size=2 %}{% /badge %} {% icon /%}",
+			schema: schema,
+			want: []string{
+				"1:1: tag tabs must not be self-closing",
+				"2:11: tag box is not allowed inside tabs",
+				"3:1: tag tab is missing required attribute name",
+				"4:3: tag badge does not allow attribute size",
+				"4:3: tag badge must be self-closing",
+				"4:43: tag icon is not in the schema",
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := messages(Validate([]byte(tc.in), tc.schema))
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("Validate(%q)\n  got : %q\n  want: %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadSchema(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "markdoc.yaml")
+	body := "shortcodes:\n  note: {tag: callout}\ntags:\n  callout:\n    required: [type]\n    selfClosing: false\n"
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSchema(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	no := false
+	want := Schema{Tags: map[string]TagSchema{"callout": {Required: []string{"type"}, SelfClosing: &no}}}
+	if !reflect.DeepEqual(s, want) {
+		t.Fatalf("LoadSchema\n  got : %+v\n  want: %+v", s, want)
+	}
+}
+
+func messages(vs []Violation) []string {
+	var out []string
+	for _, v := range vs {
+		out = append(out, v.String())
+	}
+	return out
+}
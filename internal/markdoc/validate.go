@@ -0,0 +1,156 @@
+package markdoc
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schema declares the tags a Markdoc site accepts. With no tags declared,
+// any tag is allowed and only the structure is checked.
+type Schema struct {
+	Tags map[string]TagSchema `yaml:"tags" json:"tags"`
+}
+
+// TagSchema declares one tag.
+type TagSchema struct {
+	// Required attributes. The primary attribute counts under the name
+	// "primary".
+	Required []string `yaml:"required" json:"required,omitempty"`
+
+	// Attributes lists the allowed attributes; nil allows any.
+	Attributes []string `yaml:"attributes" json:"attributes,omitempty"`
+
+	// Children lists the tags allowed directly inside; nil allows any, an
+	// empty list none.
+	Children []string `yaml:"children" json:"children,omitempty"`
+
+	// SelfClosing, if set, says whether the tag must be self-closing (true)
+	// or must have a closing tag (false).
+	SelfClosing *bool `yaml:"selfClosing" json:"selfClosing,omitempty"`
+}
+
+// LoadSchema reads the tags section of a schema file, YAML or JSON.
+func LoadSchema(path string) (Schema, error) {
+	var s Schema
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	if err := yaml.Unmarshal(b, &s); err != nil {
+		return s, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Validate parses a Markdoc document and checks that its tags are balanced,
+// and that they are declared in s with their required attributes, allowed
+// attributes, allowed children and self-closing form. Violations come in
+// document order.
+func Validate(src []byte, s Schema) []Violation {
+	tags, vs := Parse(src)
+	report := func(t Tag, format string, args ...any) {
+		vs = append(vs, violation(src, t.Start, fmt.Sprintf(format, args...)))
+	}
+
+	var stack []Tag
+	for _, t := range tags {
+		if t.Name == "" {
+			continue // annotation
+		}
+		if t.Closing {
+			switch {
+			case len(stack) == 0:
+				report(t, "closing tag %s without an opening tag", t.Name)
+				continue
+			case stack[len(stack)-1].Name != t.Name:
+				if !openIn(stack, t.Name) {
+					report(t, "closing tag %s without an opening tag", t.Name)
+					continue
+				}
+				// Close the tags left open inside it.
+				for stack[len(stack)-1].Name != t.Name {
+					open := stack[len(stack)-1]
+					report(open, "tag %s is not closed before /%s", open.Name, t.Name)
+					stack = stack[:len(stack)-1]
+				}
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		ts, declared := s.Tags[t.Name]
+		if len(s.Tags) > 0 && !declared {
+			report(t, "tag %s is not in the schema", t.Name)
+		}
+		if len(stack) > 0 {
+			parent := stack[len(stack)-1].Name
+			if children := s.Tags[parent].Children; children != nil && !contains(children, t.Name) {
+				report(t, "tag %s is not allowed inside %s", t.Name, parent)
+			}
+		}
+		if declared {
+			checkAttributes(t, ts, report)
+			if ts.SelfClosing != nil && *ts.SelfClosing != t.SelfClosing {
+				if *ts.SelfClosing {
+					report(t, "tag %s must be self-closing", t.Name)
+				} else {
+					report(t, "tag %s must not be self-closing", t.Name)
+				}
+			}
+		}
+		if !t.SelfClosing {
+			stack = append(stack, t)
+		}
+	}
+	for _, open := range stack {
+		report(open, "tag %s is never closed", open.Name)
+	}
+
+	sort.SliceStable(vs, func(i, j int) bool { return vs[i].Offset < vs[j].Offset })
+	return vs
+}
+
+func checkAttributes(t Tag, ts TagSchema, report func(Tag, string, ...any)) {
+	have := map[string]bool{}
+	for _, a := range t.Attributes {
+		name := a.Name
+		if name == "" {
+			name = "primary"
+		}
+		have[name] = true
+		if ts.Attributes != nil && !contains(ts.Attributes, name) {
+			report(t, "tag %s does not allow attribute %s", t.Name, name)
+		}
+	}
+	var missing []string
+	for _, r := range ts.Required {
+		if !have[r] {
+			missing = append(missing, r)
+		}
+	}
+	if len(missing) > 0 {
+		report(t, "tag %s is missing required attribute %s", t.Name, strings.Join(missing, ", "))
+	}
+}
+
+func openIn(stack []Tag, name string) bool {
+	for _, t := range stack {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package po
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Entry is one message of a PO or POT file.
+type Entry struct {
+	Context    string   // msgctxt
+	ID         string   // msgid, the source text
+	Str        string   // msgstr, empty in a POT
+	Comments   []string // extracted comments (#.)
+	References []string // source references (#:)
+	Fuzzy      bool
+}
+
+// Header holds the metadata written into the header entry.
+type Header struct {
+	Project  string
+	Language string // empty for a POT
+}
+
+// Write renders entries as a PO file, or a POT when the entries have no
+// msgstr and h.Language is empty.
+func Write(w io.Writer, h Header, entries []Entry) error {
+	bw := bufio.NewWriter(w)
+
+	var meta strings.Builder
+	fmt.Fprintf(&meta, "Project-Id-Version: %s\n", h.Project)
+	fmt.Fprintf(&meta, "Language: %s\n", h.Language)
+	meta.WriteString("MIME-Version: 1.0\n")
+	meta.WriteString("Content-Type: text/plain; charset=UTF-8\n")
+	meta.WriteString("Content-Transfer-Encoding: 8bit\n")
+	writeString(bw, "msgid", "")
+	writeString(bw, "msgstr", meta.String())
+
+	for _, e := range entries {
+		bw.WriteString("\n")
+		for _, c := range e.Comments {
+			fmt.Fprintf(bw, "#. %s\n", c)
+		}
+		if len(e.References) > 0 {
+			fmt.Fprintf(bw, "#: %s\n", strings.Join(e.References, " "))
+		}
+		if e.Fuzzy {
+			bw.WriteString("#, fuzzy\n")
+		}
+		if e.Context != "" {
+			writeString(bw, "msgctxt", e.Context)
+		}
+		writeString(bw, "msgid", e.ID)
+		writeString(bw, "msgstr", e.Str)
+	}
+	return bw.Flush()
+}
+
+// writeString writes a keyword and its quoted value. Values with newlines
+// are split after each one, the way msgmerge lays them out.
+func writeString(w *bufio.Writer, keyword, s string) {
+	if !strings.Contains(strings.TrimSuffix(s, "\n"), "\n") {
+		fmt.Fprintf(w, "%s %s\n", keyword, quote(s))
+		return
+	}
+	fmt.Fprintf(w, "%s \"\"\n", keyword)
+	for s != "" {
+		line := s
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			line = s[:i+1]
+		}
+		fmt.Fprintf(w, "%s\n", quote(line))
+		s = s[len(line):]
+	}
+}
+
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+/* -------------------------------- Reading -------------------------------- */
+
+// Read parses a PO file. The header entry and obsolete (#~) entries are left
+// out. For plural entries only msgstr[0] is kept.
+func Read(r io.Reader) ([]Entry, error) {
+	_, entries, err := ReadWithHeader(r)
+	return entries, err
+}
+
+// ReadWithHeader is Read, also returning what the header entry says.
+func ReadWithHeader(r io.Reader) (Header, []Entry, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var h Header
+	var out []Entry
+	var cur Entry
+	var field *string // the string continuation lines append to
+	seen := false     // cur has a msgid
+
+	flush := func() {
+		switch {
+		case seen && cur.ID != "":
+			out = append(out, cur)
+		case seen && cur.Context == "":
+			h = parseHeader(cur.Str)
+		}
+		cur, field, seen = Entry{}, nil, false
+	}
+
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "":
+			field = nil
+		case strings.HasPrefix(line, "#"):
+			if seen {
+				flush()
+			}
+			switch {
+			case strings.HasPrefix(line, "#,"):
+				for _, f := range strings.Split(line[2:], ",") {
+					if strings.TrimSpace(f) == "fuzzy" {
+						cur.Fuzzy = true
+					}
+				}
+			case strings.HasPrefix(line, "#."):
+				cur.Comments = append(cur.Comments, strings.TrimSpace(line[2:]))
+			case strings.HasPrefix(line, "#:"):
+				cur.References = append(cur.References, strings.Fields(line[2:])...)
+			}
+			field = nil
+		case strings.HasPrefix(line, `"`):
+			if field == nil {
+				return h, nil, fmt.Errorf("line %d: string without keyword", n)
+			}
+			s, err := strconv.Unquote(line)
+			if err != nil {
+				return h, nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			*field += s
+		default:
+			keyword, rest, _ := strings.Cut(line, " ")
+			s, err := strconv.Unquote(strings.TrimSpace(rest))
+			if err != nil {
+				return h, nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			switch keyword {
+			case "msgctxt":
+				if seen {
+					flush()
+				}
+				cur.Context = s
+				field = &cur.Context
+			case "msgid":
+				if seen {
+					flush()
+				}
+				cur.ID = s
+				field = &cur.ID
+				seen = true
+			case "msgstr", "msgstr[0]":
+				cur.Str = s
+				field = &cur.Str
+			case "msgid_plural":
+				field = new(string)
+			default:
+				if strings.HasPrefix(keyword, "msgstr[") {
+					field = new(string)
+					break
+				}
+				return h, nil, fmt.Errorf("line %d: unknown keyword %q", n, keyword)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return h, nil, err
+	}
+	flush()
+	return h, out, nil
+}
+
+// parseHeader reads the "Name: value" lines of a header entry.
+func parseHeader(s string) Header {
+	var h Header
+	for _, line := range strings.Split(s, "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(name) {
+		case "Project-Id-Version":
+			h.Project = strings.TrimSpace(value)
+		case "Language":
+			h.Language = strings.TrimSpace(value)
+		}
+	}
+	return h
+}
@@ -0,0 +1,87 @@
+package po
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteRead(t *testing.T) {
+	t.Parallel()
+
+	entries := []Entry{
+		{Context: "content/a.md:10", ID: "Hello **world**!\n\nSecond \"para\"\tend", Str: "Hallo **Welt**!\n\nZweiter", References: []string{"content/a.md:3"}},
+		{Context: "content/a.md:fm:title", ID: `C:\path`, Comments: []string{"Front matter title"}, Fuzzy: true, Str: "x"},
+		{ID: "no context"},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, Header{Project: "p", Language: "de"}, entries); err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.String()
+	for _, want := range []string{
+		"msgid \"\"\n\"Hello **world**!\\n\"\n\"\\n\"\n\"Second \\\"para\\\"\\tend\"\n",
+		"#, fuzzy\nmsgctxt \"content/a.md:fm:title\"\nmsgid \"C:\\\\path\"\n",
+		"\"Language: de\\n\"",
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("output lacks %q:\n%s", want, doc)
+		}
+	}
+
+	h, got, err := ReadWithHeader(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h != (Header{Project: "p", Language: "de"}) {
+		t.Errorf("header = %+v", h)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Fatalf("round trip\n  got : %#v\n  want: %#v", got, entries)
+	}
+}
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		want []Entry
+	}{
+		{
+			name: "flags and comments",
+			in:   "# translator note\n#. extracted\n#: a.md:1 a.md:2\n#, fuzzy, c-format\nmsgid \"a\"\nmsgstr \"b\"\n",
+			want: []Entry{{ID: "a", Str: "b", Comments: []string{"extracted"}, References: []string{"a.md:1", "a.md:2"}, Fuzzy: true}},
+		},
+		{
+			name: "entries without blank line",
+			in:   "msgid \"a\"\nmsgstr \"1\"\nmsgctxt \"c\"\nmsgid \"b\"\nmsgstr \"2\"\n",
+			want: []Entry{{ID: "a", Str: "1"}, {Context: "c", ID: "b", Str: "2"}},
+		},
+		{
+			name: "plural keeps first form",
+			in:   "msgid \"one\"\nmsgid_plural \"many\"\nmsgstr[0] \"eins\"\nmsgstr[1] \"viele\"\n",
+			want: []Entry{{ID: "one", Str: "eins"}},
+		},
+		{
+			name: "obsolete skipped",
+			in:   "#~ msgid \"old\"\n#~ msgstr \"alt\"\n\nmsgid \"new\"\nmsgstr \"neu\"\n",
+			want: []Entry{{ID: "new", Str: "neu"}},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := Read(strings.NewReader(tc.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Read\n  got : %#v\n  want: %#v", got, tc.want)
+			}
+		})
+	}
+}
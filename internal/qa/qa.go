@@ -0,0 +1,259 @@
+// Package qa checks translations against their source text. Markup,
+// numbers, URLs, inline code and emphasis have to come through unchanged,
+// and the translation has to look like one: not a copy of the source, and
+// not much shorter or longer than it.
+package qa
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"hugotranslationstudy/internal/subtokenize"
+)
+
+// Severity is how bad an Issue is. Errors break the page; warnings need a
+// look from a reviewer.
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+)
+
+// Issue is one problem with a translation.
+type Issue struct {
+	Check    string   `json:"check"` // markup, number, url, code, emphasis, untranslated or length
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// Checker checks translations. The zero value skips the length check.
+type Checker struct {
+	Attributes []string // HTML attributes whose values are text, as for subtokenize.SubtokenizeAttributes
+	MinRatio   float64  // the shortest a translation may be, as a share of the source's length
+	MaxRatio   float64  // the longest, likewise; 0 for no limit
+	MinLength  int      // sources with fewer letters and digits skip the length check
+}
+
+// New returns a Checker with the default length limits: half to twice the
+// length of the source, for sources of at least 20 characters.
+func New(attrs []string) *Checker {
+	return &Checker{Attributes: attrs, MinRatio: 0.5, MaxRatio: 2, MinLength: 20}
+}
+
+var (
+	numberRe   = regexp.MustCompile(`\d+(?:[.,]\d+)*`)
+	urlRe      = regexp.MustCompile(`\]\(\s*<?([^)\s>]+)|(?:href|src)\s*=\s*["']([^"']+)["']|(https?://[^\s<>()"'\]]+)`)
+	emphasisRe = regexp.MustCompile(`\*+|_+|~~`)
+)
+
+// Check compares target, the translation, with source. With markdown, both
+// are Markdown and their markup is compared too; otherwise they are plain
+// text, such as a front matter value.
+func (c *Checker) Check(source, target string, markdown bool) []Issue {
+	src, trg := c.parse(source, markdown), c.parse(target, markdown)
+	srcText, trgText := text(src), text(trg)
+
+	if !hasLetters(srcText) {
+		return nil
+	}
+	if strings.TrimSpace(trgText) == "" {
+		return []Issue{{"untranslated", Error, "the translation has no text"}}
+	}
+	if strings.TrimSpace(target) == strings.TrimSpace(source) {
+		return []Issue{{"untranslated", Warning, "the translation is the same as the source"}}
+	}
+
+	var issues []Issue
+	add := func(check string, sev Severity, format string, args ...any) {
+		issues = append(issues, Issue{check, sev, fmt.Sprintf(format, args...)})
+	}
+	if markdown {
+		missing, extra := diff(markupValues(src), markupValues(trg))
+		if len(missing)+len(extra) > 0 && !sameRunes(src, trg) {
+			for _, m := range missing {
+				add("markup", Error, "markup %q is missing", m)
+			}
+			for _, m := range extra {
+				add("markup", Error, "markup %q is not in the source", m)
+			}
+		}
+		if s, t := len(subtokenize.CodeRanges([]byte(source))), len(subtokenize.CodeRanges([]byte(target))); s != t {
+			add("code", Error, "%d code span(s), the source has %d", t, s)
+		}
+		if s, t := len(emphasisRe.FindAllString(srcText, -1)), len(emphasisRe.FindAllString(trgText, -1)); t > s {
+			add("emphasis", Error, "unbalanced emphasis: %d stray *, _ or ~~ in the text", t-s)
+		}
+	}
+
+	missing, extra := diff(numbers(srcText), numbers(trgText))
+	for _, n := range missing {
+		add("number", Error, "number %s is missing", n)
+	}
+	for _, n := range extra {
+		add("number", Error, "number %s is not in the source", n)
+	}
+	missing, extra = diff(urls(source), urls(target))
+	for _, u := range missing {
+		add("url", Error, "URL %s is missing", u)
+	}
+	for _, u := range extra {
+		add("url", Error, "URL %s is not in the source", u)
+	}
+
+	if n := letters(srcText); n >= c.MinLength && n > 0 {
+		ratio := float64(letters(trgText)) / float64(n)
+		if ratio < c.MinRatio || c.MaxRatio > 0 && ratio > c.MaxRatio {
+			add("length", Warning, "the translation is %.2f times as long as the source", ratio)
+		}
+	}
+	return issues
+}
+
+// Missing returns the issue of a source that was left without a
+// translation, if it has text.
+func (c *Checker) Missing(source string, markdown bool) []Issue {
+	if !hasLetters(text(c.parse(source, markdown))) {
+		return nil
+	}
+	return []Issue{{"untranslated", Warning, "there is no translation"}}
+}
+
+// parse splits s into subtokens; plain text is a single text subtoken, and
+// so is Markdown that cannot be parsed.
+func (c *Checker) parse(s string, markdown bool) []subtokenize.Subtoken {
+	if markdown {
+		if subs, err := subtokenize.SubtokenizeAttributes([]byte(s), c.Attributes); err == nil {
+			return subs
+		}
+	}
+	return []subtokenize.Subtoken{{Type: "text", Val: s}}
+}
+
+func text(subs []subtokenize.Subtoken) string {
+	var b strings.Builder
+	for _, s := range subs {
+		if s.Type == "text" {
+			b.WriteString(s.Val)
+		}
+	}
+	return b.String()
+}
+
+// markupValues returns the markup subtokens, trimmed, leaving out those
+// that are only whitespace.
+func markupValues(subs []subtokenize.Subtoken) []string {
+	var out []string
+	for _, s := range subs {
+		if v := strings.TrimSpace(s.Val); s.Type == "markup" && v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// sameRunes reports whether the markup of a and b is made of the same
+// characters. Markup next to each other is one subtoken, so moving it
+// around can join or split subtokens without changing anything.
+func sameRunes(a, b []subtokenize.Subtoken) bool {
+	sorted := func(subs []subtokenize.Subtoken) string {
+		var rs []rune
+		for _, s := range subs {
+			if s.Type == "markup" {
+				for _, r := range s.Val {
+					if !unicode.IsSpace(r) {
+						rs = append(rs, r)
+					}
+				}
+			}
+		}
+		sort.Slice(rs, func(i, j int) bool { return rs[i] < rs[j] })
+		return string(rs)
+	}
+	return sorted(a) == sorted(b)
+}
+
+// numbers returns the numbers in s.
+func numbers(s string) []string {
+	return numberRe.FindAllString(s, -1)
+}
+
+// numberKey returns the same key for a number written with its thousands
+// and decimal separators swapped, so 1,000.5 matches 1.000,5 and 3.5
+// matches 3,5. Dropping or moving a separator changes the key.
+func numberKey(s string) string {
+	swapped := strings.Map(func(r rune) rune {
+		switch r {
+		case '.':
+			return ','
+		case ',':
+			return '.'
+		}
+		return r
+	}, s)
+	if swapped < s {
+		return swapped
+	}
+	return s
+}
+
+// urls returns the link destinations, href and src values and bare URLs
+// in s.
+func urls(s string) []string {
+	var out []string
+	for _, m := range urlRe.FindAllStringSubmatch(s, -1) {
+		for _, g := range m[1:] {
+			if g != "" {
+				out = append(out, strings.TrimRight(g, ".,;:!?"))
+			}
+		}
+	}
+	return out
+}
+
+// diff returns the values of source that target lacks and those target has
+// on top, counting repeats. Numbers are matched by numberKey.
+func diff(source, target []string) (missing, extra []string) {
+	key := func(s string) string {
+		if numberRe.FindString(s) == s {
+			return numberKey(s)
+		}
+		return s
+	}
+	count := map[string]int{}
+	for _, s := range target {
+		count[key(s)]++
+	}
+	for _, s := range source {
+		if count[key(s)] > 0 {
+			count[key(s)]--
+		} else {
+			missing = append(missing, s)
+		}
+	}
+	for _, s := range target {
+		if count[key(s)] > 0 {
+			count[key(s)]--
+			extra = append(extra, s)
+		}
+	}
+	return missing, extra
+}
+
+func hasLetters(s string) bool {
+	return strings.IndexFunc(s, unicode.IsLetter) >= 0
+}
+
+// letters counts the letters and digits of s.
+func letters(s string) int {
+	n := 0
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			n++
+		}
+	}
+	return n
+}
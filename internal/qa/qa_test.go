@@ -0,0 +1,177 @@
+package qa
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name, source, target string
+		plain                bool
+		want                 []string // check: message
+	}{
+		{
+			name:   "clean",
+			source: "Read the **[guide](/docs/guide)** for 3.5 minutes.\n",
+			target: "Lies den **[Leitfaden](/docs/guide)** 3,5 Minuten lang.\n",
+		},
+		{
+			name:   "moved markup",
+			source: "Click <a href=\"/x\">here</a> **now**.\n",
+			target: "**Jetzt** <a href=\"/x\">hier</a> klicken.\n",
+		},
+		{
+			name:   "missing markup",
+			source: "Click <a href=\"/x\">here</a> now, please.\n",
+			target: "Jetzt hier klicken, bitte schön.\n",
+			want: []string{
+				`markup: markup "<a href=\"/x\">" is missing`,
+				`markup: markup "</a>" is missing`,
+				`url: URL /x is missing`,
+			},
+		},
+		{
+			name:   "changed number",
+			source: "Wait 10 seconds, then 2 more.\n",
+			target: "Warte 12 Sekunden, dann noch 2.\n",
+			want:   []string{"number: number 10 is missing", "number: number 12 is not in the source"},
+		},
+		{
+			name:   "swapped separators",
+			source: "It costs 1,000.50 and weighs 2.5 kg.\n",
+			target: "Es kostet 1.000,50 und wiegt 2,5 kg.\n",
+		},
+		{
+			name:   "dropped decimal separator",
+			source: "Pi is about 3.14, or 1,5 in some places.\n",
+			target: "Pi ist etwa 314, oder 15 an manchen Orten.\n",
+			want: []string{
+				"number: number 3.14 is missing",
+				"number: number 1,5 is missing",
+				"number: number 314 is not in the source",
+				"number: number 15 is not in the source",
+			},
+		},
+		{
+			name:   "moved separator",
+			source: "Version 1.25 is out.\n",
+			target: "Version 12.5 ist da.\n",
+			want:   []string{"number: number 1.25 is missing", "number: number 12.5 is not in the source"},
+		},
+		{
+			name:   "changed url",
+			source: "See [the site](https://example.com/a) today.\n",
+			target: "Siehe [die Seite](https://example.com/b) heute.\n",
+			want: []string{
+				`markup: markup "](https://example.com/a)" is missing`,
+				`markup: markup "](https://example.com/b)" is not in the source`,
+				"url: URL https://example.com/a is missing",
+				"url: URL https://example.com/b is not in the source",
+			},
+		},
+		{
+			name:   "lost code",
+			source: "Run `go test` before you push.\n",
+			target: "Führe go test aus, bevor du pushst.\n",
+			want:   []string{"markup: markup \"`go test`\" is missing", "code: 0 code span(s), the source has 1"},
+		},
+		{
+			name:   "unbalanced emphasis",
+			source: "This is **very** important.\n",
+			target: "Das ist **sehr wichtig.\n",
+			want: []string{
+				`markup: markup "**" is missing`,
+				`markup: markup "**" is missing`,
+				"emphasis: unbalanced emphasis: 1 stray *, _ or ~~ in the text",
+			},
+		},
+		{
+			name:   "untranslated",
+			source: "Hello **world**.\n",
+			target: "Hello **world**.\n",
+			want:   []string{"untranslated: the translation is the same as the source"},
+		},
+		{
+			name:   "only markup left",
+			source: "Hello **world**.\n",
+			target: "****\n",
+			want:   []string{"untranslated: the translation has no text"},
+		},
+		{
+			name:   "too short",
+			source: "This sentence is long enough to be checked for its length.",
+			target: "Kurz.",
+			plain:  true,
+			want:   []string{"length: the translation is 0.09 times as long as the source"},
+		},
+		{
+			name:   "short sources skip the length check",
+			source: "Save",
+			target: "Speichern und schließen",
+			plain:  true,
+		},
+		{
+			name:   "no text",
+			source: "42",
+			target: "42",
+			plain:  true,
+		},
+		{
+			name:   "plain text is not markdown",
+			source: "Use *args here",
+			target: "Nutze *args hier",
+			plain:  true,
+		},
+	}
+	c := New(nil)
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var got []string
+			for _, is := range c.Check(tc.source, tc.target, !tc.plain) {
+				got = append(got, is.Check+": "+is.Message)
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("Check(%q, %q)\n  got : %q\n  want: %q", tc.source, tc.target, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	c := New(nil)
+	var r Report
+	r.Add(Finding{Page: "content/a.md", Unit: "t0", Line: 3, Source: "Wait 10 s.", Target: "Warte 12 s."},
+		c.Check("Wait 10 s.", "Warte 12 s.", true))
+	r.Add(Finding{Page: "content/a.md", Unit: "fm0", Source: "Title"}, c.Missing("Title", false))
+	r.Add(Finding{Page: "content/a.md", Unit: "p0", Source: "Fine text"}, c.Check("Fine text", "Guter Text", false))
+	r.AddIssue("b.xlf", Issue{"file", Error, "unit t1: target is missing inline code d2"})
+
+	if r.Units != 3 || r.Errors != 3 || r.Warnings != 1 {
+		t.Errorf("Units, Errors, Warnings = %d, %d, %d", r.Units, r.Errors, r.Warnings)
+	}
+	want := "# Translation QA report\n\n" +
+		"3 translation(s) checked: 3 error(s), 1 warning(s).\n\n" +
+		"## content/a.md\n\n" +
+		"- error, line 3, t0: number: number 10 is missing\n" +
+		"  - source: `Wait 10 s.`\n  - target: `Warte 12 s.`\n" +
+		"- error, line 3, t0: number: number 12 is not in the source\n" +
+		"  - source: `Wait 10 s.`\n  - target: `Warte 12 s.`\n" +
+		"- warning, fm0: untranslated: there is no translation\n" +
+		"  - source: `Title`\n  - target: ``\n" +
+		"\n## b.xlf\n\n" +
+		"- error: file: unit t1: target is missing inline code d2\n"
+	if got := r.Markdown(); got != want {
+		t.Errorf("Markdown() =\n%s\nwant\n%s", got, want)
+	}
+	if f := r.Findings[0]; !reflect.DeepEqual(f.Issue, Issue{"number", Error, "number 10 is missing"}) {
+		t.Errorf("Findings[0].Issue = %+v", f.Issue)
+	}
+}
@@ -0,0 +1,84 @@
+package qa
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Report gathers the issues of every translation checked in a run. It is
+// written as JSON and rendered as Markdown.
+type Report struct {
+	Units    int       `json:"units"` // translations checked
+	Errors   int       `json:"errors"`
+	Warnings int       `json:"warnings"`
+	Findings []Finding `json:"findings"`
+}
+
+// Finding is an Issue with the translation it was found in.
+type Finding struct {
+	Page   string `json:"page"`
+	Unit   string `json:"unit,omitempty"` // unit id in the delivery, e.g. t3
+	Line   int    `json:"line,omitempty"` // line of the source file
+	Source string `json:"source,omitempty"`
+	Target string `json:"target,omitempty"`
+	Issue
+}
+
+// Add records the issues of one checked translation.
+func (r *Report) Add(f Finding, issues []Issue) {
+	r.Units++
+	for _, is := range issues {
+		f.Issue = is
+		r.add(f)
+	}
+}
+
+// AddIssue records an issue that is not about one translation, such as a
+// delivery that cannot be read.
+func (r *Report) AddIssue(page string, is Issue) {
+	r.add(Finding{Page: page, Issue: is})
+}
+
+func (r *Report) add(f Finding) {
+	if f.Severity == Error {
+		r.Errors++
+	} else {
+		r.Warnings++
+	}
+	r.Findings = append(r.Findings, f)
+}
+
+// Markdown renders r as a Markdown document, with a section per page.
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	b.WriteString("# Translation QA report\n\n")
+	fmt.Fprintf(&b, "%d translation(s) checked: %d error(s), %d warning(s).\n", r.Units, r.Errors, r.Warnings)
+	page := ""
+	for i, f := range r.Findings {
+		if i == 0 || f.Page != page {
+			page = f.Page
+			fmt.Fprintf(&b, "\n## %s\n\n", page)
+		}
+		var where []string
+		if f.Line > 0 {
+			where = append(where, fmt.Sprintf("line %d", f.Line))
+		}
+		if f.Unit != "" {
+			where = append(where, f.Unit)
+		}
+		if len(where) > 0 {
+			fmt.Fprintf(&b, "- %s, %s: %s: %s\n", f.Severity, strings.Join(where, ", "), f.Check, f.Message)
+		} else {
+			fmt.Fprintf(&b, "- %s: %s: %s\n", f.Severity, f.Check, f.Message)
+		}
+		if f.Source != "" || f.Target != "" {
+			fmt.Fprintf(&b, "  - source: `%s`\n  - target: `%s`\n", oneLine(f.Source), oneLine(f.Target))
+		}
+	}
+	return b.String()
+}
+
+// oneLine collapses whitespace and backticks so s fits in a code span.
+func oneLine(s string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(s), " "), "`", "'")
+}
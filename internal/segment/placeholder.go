@@ -0,0 +1,207 @@
+package segment
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"hugotranslationstudy/internal/subtokenize"
+)
+
+// A sentence can be sent to a translator as one string, with its inline
+// markup replaced by numbered placeholders, so that words can move across a
+// link or emphasis:
+//
+//	Click <a href="/x">here</a> now.  ->  Click {1}here{/1} now.
+//
+// A pair of markup becomes {n}…{/n}, markup on its own (an image, a <br>)
+// becomes {n/}. Markup that starts or ends the sentence, such as "## " or
+// "- ", is left out of the string and put back as it was. Attribute values
+// and titles inside the markup are translated on their own.
+//
+// Text that looks like a placeholder itself is escaped with a backslash, so
+// "Use {1} here" is sent as "Use \{1} here" and the backslash is taken off
+// again when the translation is restored.
+
+// placeholderRe matches {1}, {/1} and {1/}; escapedRe also matches them
+// escaped.
+var (
+	placeholderRe = regexp.MustCompile(`\{/?\d+/?\}`)
+	escapedRe     = regexp.MustCompile(`\\?\{/?\d+/?\}`)
+)
+
+// element is the markup one placeholder stands for: a markup subtoken and
+// the unpaired markup and attribute values right after it.
+type element struct {
+	kind  byte // 'o' opens, 'c' closes, 's' stands alone
+	num   int
+	subs  []subtokenize.Subtoken
+	attrs []int // indexes into the attribute values of the sentence
+}
+
+func (e element) String() string {
+	switch e.kind {
+	case 'o':
+		return "{" + strconv.Itoa(e.num) + "}"
+	case 'c':
+		return "{/" + strconv.Itoa(e.num) + "}"
+	}
+	return "{" + strconv.Itoa(e.num) + "/}"
+}
+
+// encoding is a sentence split into the markup before it, the parts in
+// between (text, or an element) and the markup after it.
+type encoding struct {
+	lead, trail []subtokenize.Subtoken
+	parts       []any // string or element
+	attrs       []string
+}
+
+func encode(seg Segment) encoding {
+	subs := seg.Subtokens
+	partner := subtokenize.Pairs(subs)
+	loose := func(i int) bool {
+		s := subs[i]
+		if s.Type == "markup" {
+			return partner[i] < 0
+		}
+		return s.Attr != ""
+	}
+	edge := func(i int) bool {
+		s := subs[i]
+		if s.Type == "markup" {
+			return partner[i] < 0
+		}
+		return s.Attr == "" && strings.TrimSpace(s.Val) == ""
+	}
+
+	var enc encoding
+	start, stop := 0, len(subs)
+	for start < stop && edge(start) {
+		start++
+	}
+	for stop > start && edge(stop-1) {
+		stop--
+	}
+	enc.lead, enc.trail = subs[:start], subs[stop:]
+
+	num := 0
+	opened := map[int]int{} // subtoken index of an opening -> its number
+	for i := start; i < stop; {
+		s := subs[i]
+		if s.Type == "text" && s.Attr == "" {
+			enc.parts = append(enc.parts, s.Val)
+			i++
+			continue
+		}
+		var e element
+		switch p := partner[i]; {
+		case p > i:
+			num++
+			e = element{kind: 'o', num: num}
+			opened[i] = num
+		case p >= 0:
+			e = element{kind: 'c', num: opened[p]}
+		default:
+			num++
+			e = element{kind: 's', num: num}
+		}
+		for first := i; i < stop && (i == first || loose(i)); i++ {
+			if subs[i].Type == "text" {
+				e.attrs = append(e.attrs, len(enc.attrs))
+				enc.attrs = append(enc.attrs, subs[i].Val)
+			}
+			e.subs = append(e.subs, subs[i])
+		}
+		enc.parts = append(enc.parts, e)
+	}
+	return enc
+}
+
+// Placeholders returns seg as one string with placeholders for its inline
+// markup, and the attribute values inside that markup, in order.
+func Placeholders(seg Segment) (text string, attrs []string) {
+	enc := encode(seg)
+	var b strings.Builder
+	for _, p := range enc.parts {
+		switch p := p.(type) {
+		case string:
+			b.WriteString(placeholderRe.ReplaceAllString(p, `\$0`))
+		case element:
+			b.WriteString(p.String())
+		}
+	}
+	return b.String(), enc.attrs
+}
+
+// Restore turns text, the translation of the Placeholders string of seg,
+// back into subtokens, with attrs as the translations of its attribute
+// values. Placeholders may move, but each one must appear exactly once and
+// pairs must open before they close and nest. Otherwise Restore fails, since
+// the markup of the page could not be put back.
+func Restore(seg Segment, text string, attrs []string) ([]subtokenize.Subtoken, error) {
+	enc := encode(seg)
+	if len(attrs) != len(enc.attrs) {
+		return nil, fmt.Errorf("%d attribute translation(s) for %d value(s)", len(attrs), len(enc.attrs))
+	}
+	elems := map[string]element{}
+	for _, p := range enc.parts {
+		if e, ok := p.(element); ok {
+			elems[e.String()] = e
+		}
+	}
+
+	out := append([]subtokenize.Subtoken(nil), enc.lead...)
+	addText := func(s string) {
+		if s != "" {
+			out = append(out, subtokenize.Subtoken{Type: "text", Val: s})
+		}
+	}
+	seen := map[string]bool{}
+	var open []element
+	prev := 0
+	for _, m := range escapedRe.FindAllStringIndex(text, -1) {
+		ph := text[m[0]:m[1]]
+		if ph[0] == '\\' {
+			// Text, not a placeholder
+			addText(text[prev:m[0]] + ph[1:])
+			prev = m[1]
+			continue
+		}
+		addText(text[prev:m[0]])
+		prev = m[1]
+		e, ok := elems[ph]
+		if !ok {
+			return nil, fmt.Errorf("unknown placeholder %s in %q", ph, text)
+		}
+		if seen[ph] {
+			return nil, fmt.Errorf("placeholder %s appears more than once in %q", ph, text)
+		}
+		seen[ph] = true
+		switch e.kind {
+		case 'o':
+			open = append(open, e)
+		case 'c':
+			if len(open) == 0 || open[len(open)-1].num != e.num {
+				return nil, fmt.Errorf("placeholder %s is not balanced in %q", ph, text)
+			}
+			open = open[:len(open)-1]
+		}
+		next := 0
+		for _, s := range e.subs {
+			if s.Type == "text" {
+				s.Val = attrs[e.attrs[next]]
+				next++
+			}
+			out = append(out, s)
+		}
+	}
+	addText(text[prev:])
+	for _, p := range enc.parts {
+		if e, ok := p.(element); ok && !seen[e.String()] {
+			return nil, fmt.Errorf("placeholder %s is missing from %q", e, text)
+		}
+	}
+	return append(out, enc.trail...), nil
+}
@@ -0,0 +1,232 @@
+// Package segment splits the text of a page into sentences, the unit that
+// machine translation, translation memories and vendors work with.
+//
+// Breaks are decided by SRX-style rules: at every place where a sentence
+// could start, the rules are tried in order, and the first one whose Before
+// pattern matches the text up to that place and whose After pattern matches
+// the text from there decides whether to break.
+package segment
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"hugotranslationstudy/internal/subtokenize"
+)
+
+// Rule is one SRX-style rule.
+type Rule struct {
+	Break  bool
+	Before *regexp.Regexp // matched against the text before the place, anchored at its end
+	After  *regexp.Regexp // matched against the text after it, anchored at its start
+}
+
+// Segment is one sentence of a tText token, with the markup around it.
+type Segment struct {
+	Subtokens []subtokenize.Subtoken `json:"subtokens"`
+}
+
+// Segmenter splits text into sentences following the rules of one language.
+type Segmenter struct {
+	rules []Rule
+}
+
+// abbreviations lists, per language, words that end in a period without
+// ending the sentence. Entries leave out the final period.
+var abbreviations = map[string][]string{
+	"en": {"Mr", "Mrs", "Ms", "Dr", "Prof", "Sr", "Jr", "St", "vs", "etc", "e.g", "i.e", "cf", "approx",
+		"Inc", "Ltd", "Co", "Corp", "No", "Fig", "Vol", "Jan", "Feb", "Mar", "Apr", "Jun", "Jul", "Aug",
+		"Sep", "Sept", "Oct", "Nov", "Dec", "U.S", "U.K"},
+	"de": {"z.B", "d.h", "u.a", "usw", "bzw", "ca", "Nr", "Dr", "Prof", "vgl", "ggf", "inkl", "evtl",
+		"Hr", "Fr", "S", "Abb", "Jh", "St"},
+	"fr": {"M", "MM", "Mme", "Mlle", "Dr", "etc", "p.ex", "cf", "env", "av", "apr", "J.-C", "p", "chap"},
+	"es": {"Sr", "Sra", "Srta", "Dr", "Dra", "etc", "p.ej", "pág", "núm", "Ud", "Uds", "aprox", "EE.UU"},
+}
+
+// New returns the Segmenter for lang, such as "en" or "de-CH". Its rules
+// know the built-in abbreviations of the language, plus those listed in
+// extra under the language or its base language.
+func New(lang string, extra map[string][]string) *Segmenter {
+	base, _, _ := strings.Cut(strings.ToLower(lang), "-")
+	abbrs := append([]string(nil), abbreviations[base]...)
+	for l, list := range extra {
+		if l = strings.ToLower(l); l == base || l == strings.ToLower(lang) {
+			for _, a := range list {
+				abbrs = append(abbrs, strings.TrimSuffix(a, "."))
+			}
+		}
+	}
+
+	var rules []Rule
+	if len(abbrs) > 0 {
+		quoted := make([]string, len(abbrs))
+		for i, a := range abbrs {
+			quoted[i] = regexp.QuoteMeta(a)
+		}
+		rules = append(rules, rule(false, `(?:^|[\s(\["'“‘])(?:`+strings.Join(quoted, "|")+`)\.\s+`, `.`))
+	}
+	if base == "de" {
+		rules = append(rules, rule(false, `\d\.\s+`, `\p{L}`)) // am 3. Mai
+	}
+	rules = append(rules,
+		rule(false, `[.?!]\s+`, `\p{Ll}`), // a sentence does not start in lower case
+		rule(true, `[.?!…]+[\p{Pe}\p{Pf}"'’”]*\s+`, `\S`),
+		rule(true, `[。！？]+[\p{Pe}\p{Pf}」』]*\s*`, `[^\s\p{Pe}\p{Pf}]`),
+	)
+	return &Segmenter{rules: rules}
+}
+
+func rule(brk bool, before, after string) Rule {
+	return Rule{
+		Break:  brk,
+		Before: regexp.MustCompile(`(?:` + before + `)$`),
+		After:  regexp.MustCompile(`^(?:` + after + `)`),
+	}
+}
+
+// window is how much text around a place the rules see.
+const window = 80
+
+// Breaks returns the offsets in text where a sentence starts, not
+// counting 0.
+func (s *Segmenter) Breaks(text string) []int {
+	var out []int
+	for i, r := range text {
+		if i == 0 || unicode.IsSpace(r) {
+			continue
+		}
+		prev, _ := utf8.DecodeLastRuneInString(text[:i])
+		if !unicode.IsSpace(prev) && !strings.ContainsRune("。！？」』）", prev) {
+			continue
+		}
+		before, after := text[max(0, i-window):i], text[i:min(len(text), i+window)]
+		for _, r := range s.rules {
+			if r.Before.MatchString(before) && r.After.MatchString(after) {
+				if r.Break {
+					out = append(out, i)
+				}
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Split groups the subtokens of a tText token, whose value is source, into
+// sentences. Every block (paragraph, heading, list item, table cell) starts
+// a new segment, and blocks are split where the rules break. There are no
+// breaks inside inline markup such as links or emphasis, nor inside
+// attribute values. Text subtokens are cut where a sentence ends; put
+// together, the segments give back source.
+func (s *Segmenter) Split(source string, subs []subtokenize.Subtoken) []Segment {
+	if len(subs) == 0 {
+		return nil
+	}
+	starts := make([]int, len(subs)+1) // offset of each subtoken in source
+	for i, st := range subs {
+		starts[i+1] = starts[i] + len(st.Val)
+	}
+
+	// Subtokens inside a pair of inline markup
+	inside := make([]bool, len(subs))
+	for i, p := range subtokenize.Pairs(subs) {
+		for j := i + 1; j < p; j++ {
+			inside[j] = true
+		}
+	}
+
+	cuts := map[int]bool{}
+	prevEnd := 0
+	for _, b := range subtokenize.Blocks([]byte(source)) {
+		// From the start of the line, so "## " or "- " go with the text
+		lineStart := strings.LastIndexByte(source[:b.Start], '\n') + 1
+		cuts[max(lineStart, prevEnd)] = true
+		prevEnd = b.Stop
+
+		// The text of the block, and where each byte of it came from
+		var plain strings.Builder
+		var origin []int
+		for i, st := range subs {
+			if st.Type != "text" || starts[i] < b.Start || starts[i+1] > b.Stop {
+				continue
+			}
+			plain.WriteString(st.Val)
+			for k := 0; k < len(st.Val); k++ {
+				origin = append(origin, starts[i]+k)
+			}
+		}
+		for _, p := range s.Breaks(plain.String()) {
+			// Cut right after the last byte of the sentence, so markup
+			// that follows it starts the next one.
+			last := origin[p-1]
+			i := sort.Search(len(subs), func(i int) bool { return starts[i+1] > last })
+			if inside[i] || subs[i].Attr != "" {
+				continue
+			}
+			cuts[last+1] = true
+		}
+	}
+
+	// Cut the subtokens, then group them
+	var segs []Segment
+	var cur []subtokenize.Subtoken
+	for i, st := range subs {
+		if cuts[starts[i]] && len(cur) > 0 {
+			segs, cur = append(segs, Segment{cur}), nil
+		}
+		from := starts[i]
+		for off := starts[i] + 1; off < starts[i+1]; off++ {
+			if cuts[off] {
+				cur = append(cur, subtokenize.Subtoken{Type: st.Type, Val: source[from:off], Attr: st.Attr})
+				segs, cur = append(segs, Segment{cur}), nil
+				from = off
+			}
+		}
+		cur = append(cur, subtokenize.Subtoken{Type: st.Type, Val: source[from:starts[i+1]], Attr: st.Attr})
+	}
+	segs = append(segs, Segment{cur})
+	return mergeMarkupOnly(segs)
+}
+
+// mergeMarkupOnly joins segments without text to the segment after them,
+// or to the last one at the end.
+func mergeMarkupOnly(segs []Segment) []Segment {
+	var out []Segment
+	var pending []subtokenize.Subtoken
+	for _, seg := range segs {
+		pending = append(pending, seg.Subtokens...)
+		if hasText(seg.Subtokens) {
+			out = append(out, Segment{pending})
+			pending = nil
+		}
+	}
+	if len(pending) > 0 {
+		if len(out) == 0 {
+			return []Segment{{pending}}
+		}
+		last := &out[len(out)-1]
+		last.Subtokens = append(last.Subtokens, pending...)
+	}
+	return out
+}
+
+func hasText(subs []subtokenize.Subtoken) bool {
+	for _, s := range subs {
+		if s.Type == "text" && strings.TrimSpace(s.Val) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Flatten returns the subtokens of segs in order.
+func Flatten(segs []Segment) []subtokenize.Subtoken {
+	var out []subtokenize.Subtoken
+	for _, seg := range segs {
+		out = append(out, seg.Subtokens...)
+	}
+	return out
+}
@@ -0,0 +1,272 @@
+package segment
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hugotranslationstudy/internal/subtokenize"
+)
+
+func TestBreaks(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name, lang, in string
+		extra          map[string][]string
+		want           []string
+	}{
+		{
+			name: "plain",
+			lang: "en",
+			in:   "One. Two? Three! \"Four.\" Five",
+			want: []string{"One. ", "Two? ", "Three! ", "\"Four.\" ", "Five"},
+		},
+		{
+			name: "abbreviations and lower case",
+			lang: "en-US",
+			in:   "Ask Dr. Who, e.g. today. See Fig. 3 and 3.5 kg. ok then. Done",
+			want: []string{"Ask Dr. Who, e.g. today. ", "See Fig. 3 and 3.5 kg. ok then. ", "Done"},
+		},
+		{
+			name: "german",
+			lang: "de",
+			in:   "Das ist z.B. gut. Am 3. Mai kommt er. Ende",
+			want: []string{"Das ist z.B. gut. ", "Am 3. Mai kommt er. ", "Ende"},
+		},
+		{
+			name:  "extra abbreviations",
+			lang:  "en-GB",
+			in:    "Use approx. Ten. Or not.",
+			extra: map[string][]string{"EN": {"approx."}},
+			want:  []string{"Use approx. Ten. ", "Or not."},
+		},
+		{
+			name: "no abbreviations for the language",
+			lang: "xx",
+			in:   "Dr. Who. Yes",
+			want: []string{"Dr. ", "Who. ", "Yes"},
+		},
+		{
+			name: "cjk",
+			lang: "zh",
+			in:   "第一句。第二句！「第三句。」最后",
+			want: []string{"第一句。", "第二句！", "「第三句。」", "最后"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var got []string
+			prev := 0
+			for _, b := range New(tc.lang, tc.extra).Breaks(tc.in) {
+				got = append(got, tc.in[prev:b])
+				prev = b
+			}
+			got = append(got, tc.in[prev:])
+			if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+				t.Errorf("Breaks(%q)\n  got : %q\n  want: %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	t.Parallel()
+
+	src := "\n## Overview\n\nMr. Smith has <span id=\"x\">one. Two</span> and **bold. Text** too! " +
+		"See [one. Two](u \"A. B\"). Next.\n\n- Item one. Item two\n- b\n\n| a. B | c |\n|---|---|\n"
+	want := []string{
+		"\n## Overview\n\n",
+		"Mr. Smith has <span id=\"x\">one. Two</span> and **bold. Text** too! ",
+		"See [one. Two](u \"A. B\"). ",
+		"Next.\n\n",
+		"- Item one. ",
+		"Item two\n",
+		"- b\n\n",
+		"| a. ",
+		"B",
+		" | c |\n|---|---|\n",
+	}
+	subs, err := subtokenize.Subtokenize([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	segs := New("en", nil).Split(src, subs)
+	var got []string
+	for _, seg := range segs {
+		got = append(got, join(seg.Subtokens))
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Split\n  got : %q\n  want: %q", got, want)
+	}
+	// Cut text keeps its type and attribute
+	if flat := Flatten(segs); len(flat) < len(subs) || join(flat) != src {
+		t.Errorf("Flatten = %q", join(flat))
+	}
+}
+
+// TestSplit_Lossless checks that the sentences of every content fixture put
+// back together give the fixture.
+func TestSplit_Lossless(t *testing.T) {
+	t.Parallel()
+
+	paths, err := filepath.Glob("../../content/*.md")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no fixtures: %v", err)
+	}
+	seg := New("en", nil)
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs, err := subtokenize.Subtokenize(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		segs := seg.Split(string(src), subs)
+		if got := join(Flatten(segs)); got != string(src) {
+			t.Errorf("%s: segments do not give back the source", path)
+		}
+		for _, s := range segs {
+			if !hasText(s.Subtokens) {
+				t.Errorf("%s: segment without text: %q", path, join(s.Subtokens))
+			}
+		}
+	}
+}
+
+func join(subs []subtokenize.Subtoken) string {
+	var b strings.Builder
+	for _, s := range subs {
+		b.WriteString(s.Val)
+	}
+	return b.String()
+}
+
+func TestPlaceholders(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name, in, text string
+		attrs          []string
+	}{
+		{
+			name: "html link",
+			in:   "Click <a href=\"/x\">here</a> now.\n",
+			text: "Click {1}here{/1} now.",
+		},
+		{
+			name: "heading and emphasis",
+			in:   "## A **bold** and _light_ move\n",
+			text: "A {1}bold{/1} and {2}light{/2} move",
+		},
+		{
+			name:  "link title and image alt",
+			in:    "See [docs](/d \"The docs\") and <img src=\"a.png\" alt=\"A cat\"> here.\n",
+			text:  "See {1}docs{/1} and {2/} here.",
+			attrs: []string{"The docs", "A cat"},
+		},
+		{
+			name: "markup around the sentence",
+			in:   "- **All bold**\n",
+			text: "All bold",
+		},
+		{
+			name: "code",
+			in:   "Run `go test` first.\n",
+			text: "Run {1/} first.",
+		},
+		{
+			name: "literal placeholders",
+			in:   "Use {1} in the **format** string, or \\{2/}.\n",
+			text: "Use \\{1} in the {1}format{/1} string, or \\\\{2/}.",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			seg := one(t, tc.in)
+			text, attrs := Placeholders(seg)
+			if text != tc.text || strings.Join(attrs, "|") != strings.Join(tc.attrs, "|") {
+				t.Errorf("Placeholders(%q) = %q, %q; want %q, %q", tc.in, text, attrs, tc.text, tc.attrs)
+			}
+			restored, err := Restore(seg, text, attrs)
+			if err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+			if got := join(restored); got != tc.in {
+				t.Errorf("Restore gave %q, want %q", got, tc.in)
+			}
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	t.Parallel()
+
+	// Click {1}here{/1} and {2}there{/2}, see {3/}.
+	const in = "Click <a href=\"/x\">here</a> and **there**, see <img src=\"i.png\">.\n"
+	tests := []struct {
+		name, text, want, err string
+	}{
+		{
+			name: "moved",
+			text: "{2}Dort{/2} und {1}hier{/1} klicken, siehe {3/}.",
+			want: "**Dort** und <a href=\"/x\">hier</a> klicken, siehe <img src=\"i.png\">.\n",
+		},
+		{
+			name: "nested",
+			text: "{1}{2}a{/2}{/1} {3/}",
+			want: "<a href=\"/x\">**a**</a> <img src=\"i.png\">\n",
+		},
+		{
+			name: "escaped",
+			text: "{1}a{/1} \\{2} {2}b{/2} {3/}",
+			want: "<a href=\"/x\">a</a> {2} **b** <img src=\"i.png\">\n",
+		},
+		{name: "dropped", text: "Click {1}here{/1} and there, see {3/}.", err: "{2} is missing"},
+		{name: "dropped standalone", text: "Click {1}here{/1} and {2}there{/2}.", err: "{3/} is missing"},
+		{name: "duplicated", text: "{1}a{/1} {2}b{/2} {3/} {3/}", err: "{3/} appears more than once"},
+		{name: "closed first", text: "{/1}a{1} {2}b{/2} {3/}", err: "{/1} is not balanced"},
+		{name: "crossed", text: "{1}a{2}b{/1}c{/2} {3/}", err: "{/1} is not balanced"},
+		{name: "unknown", text: "{1}a{/1} {2}b{/2} {3/} {4/}", err: "unknown placeholder {4/}"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := Restore(one(t, in), tc.text, nil)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("Restore(%q) error = %v, want %q", tc.text, err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Restore(%q): %v", tc.text, err)
+			}
+			if s := join(got); s != tc.want {
+				t.Errorf("Restore(%q) = %q, want %q", tc.text, s, tc.want)
+			}
+		})
+	}
+}
+
+// one returns the only sentence of src.
+func one(t *testing.T, src string) Segment {
+	t.Helper()
+	subs, err := subtokenize.Subtokenize([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	segs := New("en", nil).Split(src, subs)
+	if len(segs) != 1 {
+		t.Fatalf("%q has %d sentences", src, len(segs))
+	}
+	return segs[0]
+}
@@ -2,13 +2,16 @@ package subtokenize
 
 import (
 	"bytes"
+	"regexp"
 	"sort"
+	"strings"
 
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
 	east "github.com/yuin/goldmark/extension/ast"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 	"golang.org/x/net/html"
 
 	gmext "github.com/yuin/goldmark/extension"
@@ -19,18 +22,25 @@ import (
 type Subtoken struct {
 	Type string `json:"type"`
 	Val  string `json:"val"`
+	Attr string `json:"attr,omitempty"` // for text that becomes an HTML attribute value, the attribute
 }
 
+// DefaultAttributes lists the HTML attributes whose values Subtokenize
+// treats as text.
+var DefaultAttributes = []string{"alt", "title", "aria-label", "placeholder"}
+
 // claimedRange is a byte range in the source with a classification.
 type claimedRange struct {
 	start int
 	stop  int
 	typ   string // "text" or "markup"
+	attr  string
 }
 
 // walker collects claimed byte ranges from the Goldmark AST.
 type walker struct {
 	source     []byte
+	attrs      map[string]bool // translatable HTML attributes
 	ranges     []claimedRange
 	inCodeSpan bool
 	inAutoLink bool
@@ -38,8 +48,16 @@ type walker struct {
 
 // Subtokenize parses a tText token value into fine-grained subtokens.
 // Translatable text gets type "text"; everything else (markdown syntax,
-// HTML tags, code) gets type "markup".
+// HTML tags, code) gets type "markup", except the values of the HTML
+// attributes in DefaultAttributes.
 func Subtokenize(source []byte) ([]Subtoken, error) {
+	return SubtokenizeAttributes(source, DefaultAttributes)
+}
+
+// SubtokenizeAttributes is Subtokenize with the values of the HTML
+// attributes attrs as text. Only quoted values count; the rest of the tag,
+// quotes included, stays markup.
+func SubtokenizeAttributes(source []byte, attrs []string) ([]Subtoken, error) {
 	if len(source) == 0 {
 		return nil, nil
 	}
@@ -52,10 +70,12 @@ func Subtokenize(source []byte) ([]Subtoken, error) {
 	)
 
 	reader := text.NewReader(source)
-	doc := md.Parser().Parse(reader)
+	pc := parser.NewContext()
+	doc := md.Parser().Parse(reader, parser.WithContext(pc))
 
-	w := &walker{source: source}
+	w := &walker{source: source, attrs: attributeSet(attrs)}
 	w.walk(doc)
+	w.collectReferenceTitles(pc.References())
 
 	return w.buildSubtokens(), nil
 }
@@ -102,6 +122,12 @@ func (w *walker) walk(node ast.Node) {
 		}
 	case *ast.RawHTML:
 		segs := n.Segments
+		if segs.Len() == 1 {
+			// A tag on one line; its attribute values may be text
+			seg := segs.At(0)
+			w.claim(seg.Start, splitStartTag(string(w.source[seg.Start:seg.Stop]), w.attrs))
+			break
+		}
 		for i := 0; i < segs.Len(); i++ {
 			seg := segs.At(i)
 			if seg.Start < seg.Stop {
@@ -119,6 +145,16 @@ func (w *walker) walk(node ast.Node) {
 	case *east.TableCell:
 		// Table cells are containers; recurse via children below
 		_ = n
+	case *ast.Link:
+		// The text is in the children; the title is not a node
+		if len(n.Title) > 0 {
+			w.collectTitle(n)
+		}
+	case *ast.Image:
+		// The alt text is in the children, the title as for links
+		if len(n.Title) > 0 {
+			w.collectTitle(n)
+		}
 	}
 
 	// Recurse into children
@@ -151,6 +187,188 @@ func (w *walker) collectLines(lines *text.Segments, typ string) {
 	}
 }
 
+// collectTitle claims the title of an inline link or image, the part in
+// quotes or parentheses after the destination, as text of the HTML title
+// it becomes.
+func (w *walker) collectTitle(n ast.Node) {
+	open := w.destinationOpen(n)
+	if open < 0 {
+		return // reference link: the title is in the definition
+	}
+	if start, stop, ok := linkTitle(w.source, open); ok {
+		w.claimText(start, stop, "title")
+	}
+}
+
+// destinationOpen returns where the "(" before the destination of an inline
+// link or image is, or -1 for a reference link. Inline nodes carry no
+// position, so the label is searched for from the end of its content, or
+// for an empty label from the end of what comes before the node.
+func (w *walker) destinationOpen(n ast.Node) int {
+	from := -1
+	for c := n.LastChild(); c != nil && from < 0; c = c.PreviousSibling() {
+		from = w.stopOf(c)
+	}
+	if from < 0 {
+		from = w.startOf(n)
+	}
+	// After the content come at most closing delimiters, then "]("
+	i := bytes.IndexByte(w.source[from:], ']')
+	if i < 0 || from+i+1 >= len(w.source) || w.source[from+i+1] != '(' {
+		return -1
+	}
+	return from + i + 1
+}
+
+// stopOf returns where the source of inline n ends, or a place before its
+// closing delimiters: after its last text or raw HTML, or after the title or
+// destination of a link or image. It is -1 if n has none of those.
+func (w *walker) stopOf(n ast.Node) int {
+	switch n := n.(type) {
+	case *ast.Text:
+		return n.Segment.Stop
+	case *ast.RawHTML:
+		if l := n.Segments.Len(); l > 0 {
+			return n.Segments.At(l - 1).Stop
+		}
+		return -1
+	case *ast.Link, *ast.Image:
+		if open := w.destinationOpen(n); open >= 0 {
+			if _, stop, ok := linkTitle(w.source, open); ok {
+				return stop + 1
+			}
+			return destinationStop(w.source, open)
+		}
+	}
+	for c := n.LastChild(); c != nil; c = c.PreviousSibling() {
+		if stop := w.stopOf(c); stop >= 0 {
+			return stop
+		}
+	}
+	return -1
+}
+
+// startOf returns a place at or before the start of inline n: where what
+// comes before it ends, or else where its block starts.
+func (w *walker) startOf(n ast.Node) int {
+	for c := n.PreviousSibling(); c != nil; c = c.PreviousSibling() {
+		if stop := w.stopOf(c); stop >= 0 {
+			return stop
+		}
+	}
+	parent := n.Parent()
+	switch {
+	case parent == nil:
+		return 0
+	case parent.Type() == ast.TypeBlock && parent.Lines().Len() > 0:
+		return parent.Lines().At(0).Start
+	}
+	return w.startOf(parent)
+}
+
+// refDefRe matches a link reference definition on one line: label,
+// destination and title.
+var refDefRe = regexp.MustCompile(`(?m)^ {0,3}\[((?:[^\]\\]|\\.)+)\]:[ \t]*(?:<[^<>\n]*>|\S+)[ \t]+("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|\((?:[^()\\]|\\.)*\))[ \t]*$`)
+
+// collectReferenceTitles claims the titles of link reference definitions as
+// text. Goldmark takes definitions out of the tree, so they are found in the
+// source and kept only if Goldmark read them as definitions too.
+func (w *walker) collectReferenceTitles(refs []parser.Reference) {
+	if len(refs) == 0 {
+		return
+	}
+	labels := map[string]bool{}
+	for _, r := range refs {
+		labels[string(util.ToLinkReference(r.Label()))] = true
+	}
+	code := CodeRanges(w.source)
+	for _, m := range refDefRe.FindAllSubmatchIndex(w.source, -1) {
+		if inRanges(code, m[0]) || !labels[string(util.ToLinkReference(w.source[m[2]:m[3]]))] {
+			continue
+		}
+		w.claimText(m[4]+1, m[5]-1, "title") // inside the quotes
+	}
+}
+
+// claimText claims source[start:stop] as the text of attribute attr unless
+// it is blank.
+func (w *walker) claimText(start, stop int, attr string) {
+	if len(bytes.TrimSpace(w.source[start:stop])) == 0 {
+		return
+	}
+	w.ranges = append(w.ranges, claimedRange{start: start, stop: stop, typ: "text", attr: attr})
+}
+
+// linkTitle finds the title inside the parentheses of an inline link that
+// open at source[open]: the bytes between its quotes or parentheses.
+func linkTitle(source []byte, open int) (start, stop int, ok bool) {
+	i := destinationStop(source, open)
+	for i < len(source) && isSpace(source[i]) {
+		i++
+	}
+	if i >= len(source) {
+		return 0, 0, false
+	}
+	closer := source[i]
+	switch closer {
+	case '"', '\'':
+	case '(':
+		closer = ')'
+	default:
+		return 0, 0, false
+	}
+	for j := i + 1; j < len(source); j++ {
+		switch source[j] {
+		case '\\':
+			j++
+		case closer:
+			return i + 1, j, true
+		}
+	}
+	return 0, 0, false
+}
+
+// destinationStop returns where the destination of an inline link that
+// opens at source[open] ends: after its <...>, or at the first space or
+// unbalanced ")" outside it.
+func destinationStop(source []byte, open int) int {
+	i := open + 1
+	for i < len(source) && isSpace(source[i]) {
+		i++
+	}
+	if i < len(source) && source[i] == '<' {
+		if j := bytes.IndexByte(source[i:], '>'); j >= 0 {
+			return i + j + 1
+		}
+		return len(source)
+	}
+	depth := 0
+	for ; i < len(source); i++ {
+		switch c := source[i]; {
+		case c == '\\':
+			i++
+		case c == '(':
+			depth++
+		case c == ')' && depth == 0, isSpace(c):
+			return i
+		case c == ')':
+			depth--
+		}
+	}
+	return len(source)
+}
+
+func isSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+
+func inRanges(ranges []Range, off int) bool {
+	for _, r := range ranges {
+		if off >= r.Start && off < r.Stop {
+			return true
+		}
+	}
+	return false
+}
+
 // collectHTMLBlock extracts lines from an HTMLBlock, concatenates them,
 // and sub-parses with the HTML tokenizer for finer granularity.
 func (w *walker) collectHTMLBlock(n *ast.HTMLBlock) {
@@ -169,10 +387,11 @@ func (w *walker) collectHTMLBlock(n *ast.HTMLBlock) {
 	raw := w.source[blockStart:blockStop]
 
 	// Sub-parse with HTML tokenizer
-	subs := subtokenizeHTML(raw)
+	w.claim(blockStart, subtokenizeHTML(raw, w.attrs))
+}
 
-	// Convert sub-parsed results into claimed ranges with absolute offsets
-	offset := blockStart
+// claim adds subtokens that start at offset in the source as claimed ranges.
+func (w *walker) claim(offset int, subs []Subtoken) {
 	for _, sub := range subs {
 		subLen := len(sub.Val)
 		if subLen > 0 {
@@ -180,6 +399,7 @@ func (w *walker) collectHTMLBlock(n *ast.HTMLBlock) {
 				start: offset,
 				stop:  offset + subLen,
 				typ:   sub.Type,
+				attr:  sub.Attr,
 			})
 		}
 		offset += subLen
@@ -220,6 +440,7 @@ func (w *walker) buildSubtokens() []Subtoken {
 			result = append(result, Subtoken{
 				Type: r.typ,
 				Val:  string(w.source[r.start:r.stop]),
+				Attr: r.attr,
 			})
 		}
 
@@ -238,7 +459,8 @@ func (w *walker) buildSubtokens() []Subtoken {
 	return mergeSubtokens(result)
 }
 
-// mergeSubtokens combines adjacent subtokens with the same type.
+// mergeSubtokens combines adjacent subtokens with the same type, keeping
+// attribute values apart.
 func mergeSubtokens(in []Subtoken) []Subtoken {
 	if len(in) == 0 {
 		return nil
@@ -246,7 +468,7 @@ func mergeSubtokens(in []Subtoken) []Subtoken {
 	out := []Subtoken{in[0]}
 	for i := 1; i < len(in); i++ {
 		last := &out[len(out)-1]
-		if in[i].Type == last.Type {
+		if in[i].Type == last.Type && in[i].Attr == last.Attr {
 			last.Val += in[i].Val
 		} else {
 			out = append(out, in[i])
@@ -255,8 +477,9 @@ func mergeSubtokens(in []Subtoken) []Subtoken {
 	return out
 }
 
-// subtokenizeHTML splits raw HTML into markup (tags) and text (content).
-func subtokenizeHTML(source []byte) []Subtoken {
+// subtokenizeHTML splits raw HTML into markup (tags) and text (content and
+// the values of the attributes in attrs).
+func subtokenizeHTML(source []byte, attrs map[string]bool) []Subtoken {
 	tokenizer := html.NewTokenizer(bytes.NewReader(source))
 	var result []Subtoken
 	consumed := 0
@@ -276,6 +499,8 @@ func subtokenizeHTML(source []byte) []Subtoken {
 			if len(rawStr) > 0 {
 				result = append(result, Subtoken{Type: "text", Val: rawStr})
 			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			result = append(result, splitStartTag(rawStr, attrs)...)
 		default:
 			// StartTag, EndTag, SelfClosingTag, Comment, Doctype
 			if len(rawStr) > 0 {
@@ -291,3 +516,325 @@ func subtokenizeHTML(source []byte) []Subtoken {
 
 	return mergeSubtokens(result)
 }
+
+// attributeSet returns attrs as a set of lower-case names.
+func attributeSet(attrs []string) map[string]bool {
+	set := make(map[string]bool, len(attrs))
+	for _, a := range attrs {
+		set[strings.ToLower(a)] = true
+	}
+	return set
+}
+
+// splitStartTag splits an HTML start tag so that the quoted values of the
+// attributes in attrs become text subtokens; the rest is markup. Anything
+// that is not a well-formed start tag stays markup as a whole.
+func splitStartTag(tag string, attrs map[string]bool) []Subtoken {
+	if !strings.HasPrefix(tag, "<") || !strings.HasSuffix(tag, ">") || len(attrs) == 0 {
+		return []Subtoken{{Type: "markup", Val: tag}}
+	}
+	isSpace := func(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' }
+	i := 1
+	for i < len(tag) && !isSpace(tag[i]) && tag[i] != '>' && tag[i] != '/' {
+		i++
+	}
+	if i == 1 || !isLetter(tag[1]) {
+		return []Subtoken{{Type: "markup", Val: tag}} // end tag, comment, ...
+	}
+
+	var out []Subtoken
+	from := 0 // start of the markup not yet emitted
+	for i < len(tag) {
+		for i < len(tag) && (isSpace(tag[i]) || tag[i] == '/') {
+			i++
+		}
+		nameStart := i
+		for i < len(tag) && !isSpace(tag[i]) && tag[i] != '=' && tag[i] != '>' && tag[i] != '/' {
+			i++
+		}
+		name := tag[nameStart:i]
+		if name == "" {
+			break
+		}
+		j := i
+		for j < len(tag) && isSpace(tag[j]) {
+			j++
+		}
+		if j == len(tag) || tag[j] != '=' {
+			continue // attribute without a value
+		}
+		j++
+		for j < len(tag) && isSpace(tag[j]) {
+			j++
+		}
+		if j == len(tag) {
+			break
+		}
+		if q := tag[j]; q == '"' || q == '\'' {
+			end := strings.IndexByte(tag[j+1:], q)
+			if end < 0 {
+				break
+			}
+			val := tag[j+1 : j+1+end]
+			if attrs[strings.ToLower(name)] && strings.TrimSpace(val) != "" {
+				out = append(out,
+					Subtoken{Type: "markup", Val: tag[from : j+1]},
+					Subtoken{Type: "text", Val: val, Attr: strings.ToLower(name)})
+				from = j + 1 + end
+			}
+			i = j + 2 + end
+			continue
+		}
+		// Unquoted: a translation could not keep it one value
+		for j < len(tag) && !isSpace(tag[j]) && tag[j] != '>' {
+			j++
+		}
+		i = j
+	}
+	return append(out, Subtoken{Type: "markup", Val: tag[from:]})
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// EscapeAttribute escapes a translation for the HTML attribute value it
+// replaces: the quote that delimits the value, before is the markup in
+// front of it, is written as a character reference.
+func EscapeAttribute(val, before string) string {
+	switch {
+	case strings.HasSuffix(before, `"`):
+		return strings.ReplaceAll(val, `"`, "&quot;")
+	case strings.HasSuffix(before, "'"):
+		return strings.ReplaceAll(val, "'", "&#39;")
+	}
+	return val
+}
+
+var (
+	htmlOpenRe  = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9-]*)(\s[^>]*)?>$`)
+	htmlCloseRe = regexp.MustCompile(`^</([a-zA-Z][a-zA-Z0-9-]*)\s*>$`)
+	linkCloseRe = regexp.MustCompile(`^\](\(.*\)|\[.*\]|\(.*["'(])?$`) // "](url)", "][ref]" or `](url "` before a title
+)
+
+// htmlVoid lists elements that never have a closing tag.
+var htmlVoid = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// Pairs matches opening and closing inline markup subtokens, such as the two
+// "**" around bold text, an HTML start tag and its end tag, or the "[" and
+// "](url)" around link text. For each subtoken it returns the index of its
+// partner, or -1 for text and for markup that stands alone. Only subtokens
+// that consist of exactly one delimiter or tag are paired, and pairs never
+// cross each other.
+func Pairs(subs []Subtoken) []int {
+	partner := make([]int, len(subs))
+	for i := range partner {
+		partner[i] = -1
+	}
+
+	type open struct {
+		idx int
+		key string
+	}
+	var stack []open
+	closeTop := func(i int, key string) bool {
+		if len(stack) == 0 || stack[len(stack)-1].key != key {
+			return false
+		}
+		j := stack[len(stack)-1].idx
+		stack = stack[:len(stack)-1]
+		partner[i], partner[j] = j, i
+		return true
+	}
+
+	for i, s := range subs {
+		if s.Type != "markup" {
+			continue
+		}
+		switch v := s.Val; {
+		case v == "*" || v == "**" || v == "***" || v == "_" || v == "__" || v == "~~":
+			// Same delimiter opens and closes
+			if !closeTop(i, v) {
+				stack = append(stack, open{i, v})
+			}
+		case v == "[" || v == "![":
+			stack = append(stack, open{i, "["})
+		case linkCloseRe.MatchString(v):
+			closeTop(i, "[")
+		case htmlOpenRe.MatchString(v) && !strings.HasSuffix(v, "/>"):
+			name := strings.ToLower(htmlOpenRe.FindStringSubmatch(v)[1])
+			if !htmlVoid[name] {
+				stack = append(stack, open{i, "<" + name})
+			}
+		case htmlCloseRe.MatchString(v):
+			closeTop(i, "<"+strings.ToLower(htmlCloseRe.FindStringSubmatch(v)[1]))
+		}
+	}
+	return partner
+}
+
+// Range is a byte range [Start, Stop) in a source.
+type Range struct {
+	Start int
+	Stop  int
+}
+
+// CodeRanges returns the byte ranges of code in a Markdown source: fenced
+// code blocks including their fences, indented code blocks, and code spans
+// including their backticks. Ranges are sorted and do not overlap.
+func CodeRanges(source []byte) []Range {
+	if len(source) == 0 {
+		return nil
+	}
+	md := goldmark.New(goldmark.WithExtensions(gmext.NewTable()))
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	var out []Range
+	_ = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.FencedCodeBlock:
+			if r, ok := fencedRange(source, n); ok {
+				out = append(out, r)
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeBlock:
+			if lines := n.Lines(); lines.Len() > 0 {
+				out = append(out, Range{lines.At(0).Start, lines.At(lines.Len() - 1).Stop})
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeSpan:
+			first, last := n.FirstChild(), n.LastChild()
+			ft, ok1 := first.(*ast.Text)
+			lt, ok2 := last.(*ast.Text)
+			if !ok1 || !ok2 {
+				return ast.WalkSkipChildren, nil
+			}
+			// Goldmark strips one space of padding inside the backticks
+			start, stop := ft.Segment.Start, lt.Segment.Stop
+			if start > 1 && source[start-1] == ' ' && source[start-2] == '`' {
+				start--
+			}
+			for start > 0 && source[start-1] == '`' {
+				start--
+			}
+			if stop+1 < len(source) && source[stop] == ' ' && source[stop+1] == '`' {
+				stop++
+			}
+			for stop < len(source) && source[stop] == '`' {
+				stop++
+			}
+			out = append(out, Range{start, stop})
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Start < out[j].Start })
+	merged := out[:0]
+	for _, r := range out {
+		if n := len(merged); n > 0 && r.Start <= merged[n-1].Stop {
+			if r.Stop > merged[n-1].Stop {
+				merged[n-1].Stop = r.Stop
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// Blocks returns the byte ranges of the blocks of source that hold text:
+// paragraphs, headings, list item text, table cells and HTML blocks, in
+// order. A sentence never spans two of them.
+func Blocks(source []byte) []Range {
+	if len(source) == 0 {
+		return nil
+	}
+	md := goldmark.New(goldmark.WithExtensions(gmext.NewTable()))
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	var out []Range
+	_ = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node.(type) {
+		case *ast.Paragraph, *ast.Heading, *ast.TextBlock, *ast.HTMLBlock, *east.TableCell:
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			return ast.WalkSkipChildren, nil
+		default:
+			return ast.WalkContinue, nil
+		}
+		r := Range{Start: -1}
+		extend := func(seg text.Segment) {
+			if r.Start < 0 || seg.Start < r.Start {
+				r.Start = seg.Start
+			}
+			if seg.Stop > r.Stop {
+				r.Stop = seg.Stop
+			}
+		}
+		for i := 0; i < node.Lines().Len(); i++ {
+			extend(node.Lines().At(i))
+		}
+		_ = ast.Walk(node, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+			if t, ok := c.(*ast.Text); ok && entering {
+				extend(t.Segment)
+			}
+			return ast.WalkContinue, nil
+		})
+		if r.Start >= 0 && r.Start < r.Stop {
+			out = append(out, r)
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Start < out[j].Start })
+	return out
+}
+
+// fencedRange extends a fenced code block from its content lines to the
+// opening and (if present) closing fence lines.
+func fencedRange(source []byte, n *ast.FencedCodeBlock) (Range, bool) {
+	var anchor int
+	switch {
+	case n.Lines().Len() > 0:
+		anchor = n.Lines().At(0).Start
+		// The opening fence is the line before the first content line
+		anchor = bytes.LastIndexByte(source[:anchor], '\n')
+		if anchor < 0 {
+			anchor = 0
+		}
+	case n.Info != nil:
+		anchor = n.Info.Segment.Start
+	default:
+		return Range{}, false
+	}
+	start := bytes.LastIndexByte(source[:anchor], '\n') + 1
+
+	stop := anchor
+	if n.Lines().Len() > 0 {
+		stop = n.Lines().At(n.Lines().Len() - 1).Stop
+	} else if i := bytes.IndexByte(source[anchor:], '\n'); i >= 0 {
+		stop = anchor + i + 1
+	} else {
+		stop = len(source)
+	}
+	// Closing fence: the next line, if it starts with ``` or ~~~
+	rest := source[stop:]
+	line := rest
+	if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+		line = rest[:i]
+	}
+	trimmed := bytes.TrimLeft(line, " ")
+	if bytes.HasPrefix(trimmed, []byte("```")) || bytes.HasPrefix(trimmed, []byte("~~~")) {
+		stop += len(line)
+	}
+	return Range{start, stop}, true
+}
@@ -155,6 +155,87 @@ func TestSubtokenize_Link(t *testing.T) {
 	assertSubtokens(t, subs, want)
 }
 
+func TestSubtokenize_Titles(t *testing.T) {
+	source := "An ![Scenic *pic*](a.png \"A view\") and [docs](<https://x.org/a b> 'The \\'docs\\'').\n\n" +
+		"[Ref][1], [other](/o (Paren)) and [no title](/n).\n\n" +
+		"[1]: https://ref.org \"Ref title\"\n" +
+		"    [2]: /code \"Not a definition\"\n"
+	subs, err := Subtokenize([]byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertReversible(t, source, subs)
+
+	want := []Subtoken{
+		{Type: "text", Val: "An "},
+		{Type: "markup", Val: "!["},
+		{Type: "text", Val: "Scenic "},
+		{Type: "markup", Val: "*"},
+		{Type: "text", Val: "pic"},
+		{Type: "markup", Val: "*](a.png \""},
+		{Type: "text", Val: "A view"},
+		{Type: "markup", Val: "\")"},
+		{Type: "text", Val: " and "},
+		{Type: "markup", Val: "["},
+		{Type: "text", Val: "docs"},
+		{Type: "markup", Val: "](<https://x.org/a b> '"},
+		{Type: "text", Val: "The \\'docs\\'"},
+		{Type: "markup", Val: "')"},
+		{Type: "text", Val: "."},
+		{Type: "markup", Val: "\n\n["},
+		{Type: "text", Val: "Ref"},
+		{Type: "markup", Val: "][1]"},
+		{Type: "text", Val: ", "},
+		{Type: "markup", Val: "["},
+		{Type: "text", Val: "other"},
+		{Type: "markup", Val: "](/o ("},
+		{Type: "text", Val: "Paren"},
+		{Type: "markup", Val: "))"},
+		{Type: "text", Val: " and "},
+		{Type: "markup", Val: "["},
+		{Type: "text", Val: "no title"},
+		{Type: "markup", Val: "](/n)"},
+		{Type: "text", Val: "."},
+		{Type: "markup", Val: "\n\n[1]: https://ref.org \""},
+		{Type: "text", Val: "Ref title"},
+		{Type: "markup", Val: "\"\n    [2]: /code \"Not a definition\"\n"},
+	}
+	assertSubtokens(t, subs, want)
+
+	for _, i := range []int{6, 12, 22, 30} {
+		if subs[i].Attr != "title" {
+			t.Errorf("subtoken %d (%q): attr %q, want title", i, subs[i].Val, subs[i].Attr)
+		}
+	}
+
+	// The brackets around link text still pair when a title follows
+	pairs := Pairs(subs)
+	if pairs[9] != 11 || pairs[19] != 21 {
+		t.Errorf("Pairs = %v", pairs)
+	}
+}
+
+func TestSubtokenize_EmptyLinkTitles(t *testing.T) {
+	source := "[](u \"One\") ![](i.png \"Two\") [a](x \"Three\")[](y \"Four\") [![](i \"Five\")](u \"Six\")\n\n" +
+		"| *x*[](u \"Seven\") |\n|---|\n"
+	subs, err := Subtokenize([]byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertReversible(t, source, subs)
+
+	var titles []string
+	for _, s := range subs {
+		if s.Attr == "title" {
+			titles = append(titles, s.Val)
+		}
+	}
+	want := []string{"One", "Two", "Three", "Four", "Five", "Six", "Seven"}
+	if strings.Join(titles, "|") != strings.Join(want, "|") {
+		t.Errorf("titles = %q, want %q", titles, want)
+	}
+}
+
 func TestSubtokenize_Heading(t *testing.T) {
 	source := "## Overview\n"
 	subs, err := Subtokenize([]byte(source))
@@ -199,6 +280,8 @@ func TestSubtokenize_Reversibility(t *testing.T) {
 		"See the [documentation](https://example.com) for details.\n",
 		"## Overview\n",
 		"Use the `fmt.Println` function.\n",
+		"<img src=\"a.png\" alt=\"A diagram\" title='It\\'s' data-x=1 alt=unquoted>\n",
+		"An <abbr\ntitle=\"HyperText\">HTML</abbr> <input placeholder = \"Name\" />.\n",
 		// The full 03_fences_and_html.md tText value
 		"\n## Overview\n\nThis file contains <span id=\"some-span\">raw html</span> and some code fences. There is also **bold text**.\n\n```javascript\nconsole.log(\"Hello world\")\n```\n\n<div class=\"alert alert-info\">When in doubt, just ask <a href=\"https://www.google.com\">Google</a>!<div>",
 		// Edge cases
@@ -223,9 +306,65 @@ func TestSubtokenize_Reversibility(t *testing.T) {
 	}
 }
 
+func TestSubtokenize_HTMLAttributes(t *testing.T) {
+	source := "<p><img src=\"a.png\" alt=\"Diagram\" title=''>\n<abbr TITLE='Hyper \"Text\"'>HT</abbr></p>\n\n" +
+		"Inline <input placeholder = \"Your name\" aria-label=x>, <span alt=\" \">.\n"
+	subs, err := Subtokenize([]byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertReversible(t, source, subs)
+
+	want := []Subtoken{
+		{Type: "markup", Val: "<p><img src=\"a.png\" alt=\""},
+		{Type: "text", Val: "Diagram", Attr: "alt"},
+		{Type: "markup", Val: "\" title=''>"},
+		{Type: "text", Val: "\n"},
+		{Type: "markup", Val: "<abbr TITLE='"},
+		{Type: "text", Val: "Hyper \"Text\"", Attr: "title"},
+		{Type: "markup", Val: "'>"},
+		{Type: "text", Val: "HT"},
+		{Type: "markup", Val: "</abbr></p>"},
+		{Type: "text", Val: "\n"},
+		{Type: "markup", Val: "\n"},
+		{Type: "text", Val: "Inline "},
+		{Type: "markup", Val: "<input placeholder = \""},
+		{Type: "text", Val: "Your name", Attr: "placeholder"},
+		{Type: "markup", Val: "\" aria-label=x>"},
+		{Type: "text", Val: ", "},
+		{Type: "markup", Val: "<span alt=\" \">"},
+		{Type: "text", Val: "."},
+		{Type: "markup", Val: "\n"},
+	}
+	assertSubtokens(t, subs, want)
+	for i := range want {
+		if subs[i].Attr != want[i].Attr {
+			t.Errorf("subtoken %d: attr %q, want %q", i, subs[i].Attr, want[i].Attr)
+		}
+	}
+
+	// Without attributes, tags stay whole
+	subs, _ = SubtokenizeAttributes([]byte(source), nil)
+	if subs[0].Val != "<p><img src=\"a.png\" alt=\"Diagram\" title=''>" {
+		t.Errorf("first subtoken without attributes = %q", subs[0].Val)
+	}
+}
+
+func TestEscapeAttribute(t *testing.T) {
+	for _, tc := range []struct{ val, before, want string }{
+		{`Say "hi"`, `<img alt="`, "Say &quot;hi&quot;"},
+		{`It's`, `<img alt='`, "It&#39;s"},
+		{`It's "x"`, "Text ", `It's "x"`},
+	} {
+		if got := EscapeAttribute(tc.val, tc.before); got != tc.want {
+			t.Errorf("EscapeAttribute(%q, %q) = %q, want %q", tc.val, tc.before, got, tc.want)
+		}
+	}
+}
+
 func TestSubtokenizeHTML(t *testing.T) {
 	source := `<div class="alert alert-info">When in doubt, just ask <a href="https://www.google.com">Google</a>!<div>`
-	subs := subtokenizeHTML([]byte(source))
+	subs := subtokenizeHTML([]byte(source), attributeSet(DefaultAttributes))
 
 	// Verify reversibility
 	var buf bytes.Buffer
@@ -328,3 +467,45 @@ func TestSubtokenize_FullExample(t *testing.T) {
 		t.Error("expected 'raw html' in a text subtoken")
 	}
 }
+
+func TestPairs(t *testing.T) {
+	subs := []Subtoken{
+		{Type: "text", Val: "Hello "},                           // 0
+		{Type: "markup", Val: "**"},                             // 1
+		{Type: "text", Val: "bold "},                            // 2
+		{Type: "markup", Val: "["},                              // 3
+		{Type: "text", Val: "link"},                             // 4
+		{Type: "markup", Val: "](https://example.com)"},         // 5
+		{Type: "markup", Val: "**"},                             // 6
+		{Type: "markup", Val: `<a href="https://example.com">`}, // 7
+		{Type: "text", Val: "here"},                             // 8
+		{Type: "markup", Val: "</a>"},                           // 9
+		{Type: "markup", Val: "<br>"},                           // 10
+		{Type: "markup", Val: "</span>"},                        // 11
+		{Type: "markup", Val: "\n"},                             // 12
+	}
+	got := Pairs(subs)
+	want := []int{-1, 6, -1, 5, -1, 3, 1, 9, -1, 7, -1, -1, -1}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Pairs mismatch at %d: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestCodeRanges(t *testing.T) {
+	source := "Inline `{{< a >}}` and ``b ` c``.\n\n```go\nx := \"{{< b >}}\"\n```\n\n    indented {{< c >}}\n\n~~~\n~~~\n\nText {{< d >}}\n"
+	var got []string
+	for _, r := range CodeRanges([]byte(source)) {
+		got = append(got, source[r.Start:r.Stop])
+	}
+	want := []string{
+		"`{{< a >}}`",
+		"``b ` c``",
+		"```go\nx := \"{{< b >}}\"\n```",
+		"indented {{< c >}}\n", // Goldmark's segments start after the indentation
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("CodeRanges:\n  got : %q\n  want: %q", got, want)
+	}
+}
@@ -0,0 +1,234 @@
+package tm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultMinScore is the lowest similarity reported as a fuzzy match.
+const DefaultMinScore = 0.75
+
+// Entry is one translation unit of the memory, stored as a line of JSON.
+type Entry struct {
+	SourceLocale string `json:"sourceLocale"`
+	TargetLocale string `json:"targetLocale"`
+	Source       string `json:"source"`
+	Target       string `json:"target"`
+}
+
+// Match is a fuzzy match for a segment: a similar (normalized) source text in
+// the memory and its translation. Score is 1 for identical text.
+type Match struct {
+	TargetLocale string  `json:"targetLocale"`
+	Segment      string  `json:"segment"`
+	Source       string  `json:"source"`
+	Target       string  `json:"target"`
+	Score        float64 `json:"score"`
+}
+
+// Memory is a file-based translation memory. Lookups are keyed by the
+// normalized source text (see Normalize) and locale pair; later entries for
+// the same key win.
+type Memory struct {
+	path    string
+	entries map[key]string
+	pending []Entry // added since Open, appended by Save
+}
+
+type key struct {
+	sourceLocale, targetLocale, source string
+}
+
+// Open loads the memory stored at path. A missing file is an empty memory.
+func Open(path string) (*Memory, error) {
+	m := &Memory{path: path, entries: map[key]string{}}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		m.entries[key{e.SourceLocale, e.TargetLocale, Normalize(e.Source)}] = e.Target
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Len returns the number of distinct entries.
+func (m *Memory) Len() int { return len(m.entries) }
+
+// Entries returns all entries, sorted by locales and source text.
+func (m *Memory) Entries() []Entry {
+	out := make([]Entry, 0, len(m.entries))
+	for k, t := range m.entries {
+		out = append(out, Entry{k.sourceLocale, k.targetLocale, k.source, t})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.SourceLocale != b.SourceLocale {
+			return a.SourceLocale < b.SourceLocale
+		}
+		if a.TargetLocale != b.TargetLocale {
+			return a.TargetLocale < b.TargetLocale
+		}
+		return a.Source < b.Source
+	})
+	return out
+}
+
+// Normalize collapses runs of whitespace to a single space and trims the
+// ends, so that a text subtoken matches however it was wrapped or spaced.
+func Normalize(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Lookup returns the stored translation of source, if any, with the leading
+// and trailing whitespace of source around it.
+func (m *Memory) Lookup(source, sourceLocale, targetLocale string) (string, bool) {
+	n := Normalize(source)
+	if n == "" {
+		return "", false
+	}
+	t, ok := m.entries[key{sourceLocale, targetLocale, n}]
+	if !ok {
+		return "", false
+	}
+	lead := source[:len(source)-len(strings.TrimLeftFunc(source, unicode.IsSpace))]
+	trail := source[len(strings.TrimRightFunc(source, unicode.IsSpace)):]
+	return lead + t + trail, true
+}
+
+// Add records a translation. Blank sources are ignored. The entry is kept
+// for Save unless the memory already holds exactly this translation.
+func (m *Memory) Add(source, target, sourceLocale, targetLocale string) {
+	k := key{sourceLocale, targetLocale, Normalize(source)}
+	target = strings.TrimSpace(target)
+	if k.source == "" {
+		return
+	}
+	if t, ok := m.entries[k]; ok && t == target {
+		return
+	}
+	m.entries[k] = target
+	m.pending = append(m.pending, Entry{sourceLocale, targetLocale, k.source, target})
+}
+
+// Fuzzy returns the most similar stored source text for the locale pair,
+// if its score is at least minScore. Blank segments never match.
+func (m *Memory) Fuzzy(segment, sourceLocale, targetLocale string, minScore float64) (Match, bool) {
+	norm := Normalize(segment)
+	if norm == "" {
+		return Match{}, false
+	}
+	best := Match{TargetLocale: targetLocale, Segment: segment}
+	n := utf8.RuneCountInString(norm)
+	for k, t := range m.entries {
+		if k.sourceLocale != sourceLocale || k.targetLocale != targetLocale {
+			continue
+		}
+		// The distance is at least the length difference, so skip texts
+		// that cannot reach minScore (or beat the best so far).
+		kn := utf8.RuneCountInString(k.source)
+		if maxScore(n, kn) < minScore || maxScore(n, kn) < best.Score {
+			continue
+		}
+		s := Similarity(norm, k.source)
+		if s > best.Score || s == best.Score && k.source < best.Source {
+			best.Source, best.Target, best.Score = k.source, t, s
+		}
+	}
+	return best, best.Score >= minScore && best.Source != ""
+}
+
+func maxScore(a, b int) float64 {
+	if a < b {
+		a, b = b, a
+	}
+	if a == 0 {
+		return 1
+	}
+	return float64(b) / float64(a)
+}
+
+// Save appends the entries added since Open to the memory file.
+func (m *Memory) Save() error {
+	if len(m.pending) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	for _, e := range m.pending {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("write %s: %w", m.path, err)
+	}
+	m.pending = nil
+	return f.Close()
+}
+
+// Similarity is 1 minus the Levenshtein distance between a and b (in runes),
+// divided by the length of the longer one.
+func Similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,184 @@
+package tm
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSimilarity(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"abc", "abc", 1},
+		{"abc", "", 0},
+		{"kitten", "sitting", 1 - 3.0/7},
+		{"héllo", "hello", 0.8}, // runes, not bytes
+	}
+	for _, tc := range tests {
+		if got := Similarity(tc.a, tc.b); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("Similarity(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "tm.jsonl")
+	m, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Add("More text after the shortcode.", "Mehr Text nach dem Shortcode.", "en", "de")
+	m.Add("Hello", "Hallo", "en", "de")
+	m.Add("Hello", "Hola", "en", "es")
+	m.Add("Hello", "Hallo", "en", "de") // unchanged: not written twice
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen: entries survive, later lines win
+	m, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Add("Hello", "Servus", "en", "de")
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+	m, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := m.Lookup("Hello", "en", "de"); !ok || got != "Servus" {
+		t.Errorf("Lookup(Hello, de) = %q, %v", got, ok)
+	}
+	if got, ok := m.Lookup("Hello", "en", "es"); !ok || got != "Hola" {
+		t.Errorf("Lookup(Hello, es) = %q, %v", got, ok)
+	}
+	if _, ok := m.Lookup("hello", "en", "de"); ok {
+		t.Error("Lookup ignored case")
+	}
+	if m.Len() != 3 {
+		t.Errorf("Len = %d, want 3", m.Len())
+	}
+	b, _ := os.ReadFile(path)
+	if lines := len(strings.Split(strings.TrimSpace(string(b)), "\n")); lines != 4 {
+		t.Errorf("file has %d lines, want 4:\n%s", lines, b)
+	}
+
+	match, ok := m.Fuzzy("More text right after the shortcode.", "en", "de", DefaultMinScore)
+	want := Match{
+		TargetLocale: "de",
+		Segment:      "More text right after the shortcode.",
+		Source:       "More text after the shortcode.",
+		Target:       "Mehr Text nach dem Shortcode.",
+		Score:        1 - 6.0/36,
+	}
+	if !ok || !reflect.DeepEqual(match, want) {
+		t.Errorf("Fuzzy = %+v, %v\n want %+v", match, ok, want)
+	}
+	if _, ok := m.Fuzzy("Something else entirely", "en", "de", DefaultMinScore); ok {
+		t.Error("Fuzzy matched an unrelated segment")
+	}
+	if _, ok := m.Fuzzy("More text right after the shortcode.", "en", "es", DefaultMinScore); ok {
+		t.Error("Fuzzy matched across locales")
+	}
+}
+
+func TestLookupNormalized(t *testing.T) {
+	t.Parallel()
+
+	m, err := Open(filepath.Join(t.TempDir(), "tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Add(" This document\nstress-tests ", "Isthay ocumentday\nessstray-eststay ", "en", "x")
+	if got, ok := m.Lookup("\nThis  document stress-tests ", "en", "x"); !ok || got != "\nIsthay ocumentday\nessstray-eststay " {
+		t.Errorf("Lookup = %q, %v", got, ok)
+	}
+	m.Add("  ", "  ", "en", "x")
+	if m.Len() != 1 {
+		t.Errorf("blank source was stored")
+	}
+}
+
+func TestReadTMX(t *testing.T) {
+	t.Parallel()
+
+	const doc = `<?xml version="1.0"?>
+<tmx version="1.4">
+  <header srclang="en-US" creationtool="x" segtype="sentence" o-tmf="x" adminlang="en" datatype="html"/>
+  <body>
+    <tu>
+      <prop type="x-note">ignored</prop>
+      <tuv xml:lang="en-US"><seg>Click <bpt i="1">&lt;b&gt;</bpt>Save<ept i="1">&lt;/b&gt;</ept> now.</seg></tuv>
+      <tuv xml:lang="de-DE"><seg>Klicken Sie <bpt i="1">&lt;b&gt;</bpt>Speichern<ept i="1">&lt;/b&gt;</ept>.</seg></tuv>
+      <tuv xml:lang="fr-FR"><seg><bpt i="1">&lt;b&gt;</bpt>Enregistrer<ept i="1">&lt;/b&gt;</ept></seg></tuv>
+    </tu>
+    <tu>
+      <tuv xml:lang="en-US"><seg>Plain <hi>text</hi></seg></tuv>
+      <tuv xml:lang="de-DE"><seg>Reiner Text</seg></tuv>
+    </tu>
+  </body>
+</tmx>`
+
+	entries, skipped, err := ReadTMX(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{"en-US", "de-DE", "Click", "Klicken Sie"},
+		{"en-US", "de-DE", "Save", "Speichern"},
+		{"en-US", "de-DE", "now.", "."},
+		{"en-US", "de-DE", "Plain text", "Reiner Text"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("entries\n  got : %v\n  want: %v", entries, want)
+	}
+	if skipped != 1 {
+		t.Errorf("skipped = %d, want 1 (the French variant)", skipped)
+	}
+
+	if !LocaleMatches("en-US", "en") || !LocaleMatches("EN", "en") || LocaleMatches("eng", "en") {
+		t.Error("LocaleMatches")
+	}
+}
+
+func TestTMXRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	entries := []Entry{
+		{"en", "de", "1. Standalone & more", "1. Eigenständig & mehr"},
+		{"en", "de", "Some **bold** text", "Etwas **fetter** Text"},
+	}
+	var buf strings.Builder
+	if err := WriteTMX(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<seg>Some <ph x="1">**</ph>bold<ph x="2">**</ph> text</seg>`) {
+		t.Errorf("inline markup not written as <ph>:\n%s", buf.String())
+	}
+
+	got, skipped, err := ReadTMX(strings.NewReader(buf.String()))
+	if err != nil || skipped != 0 {
+		t.Fatalf("ReadTMX: %v (skipped %d)", err, skipped)
+	}
+	want := []Entry{
+		{"en", "de", "1. Standalone & more", "1. Eigenständig & mehr"},
+		{"en", "de", "Some", "Etwas"},
+		{"en", "de", "bold", "fetter"},
+		{"en", "de", "text", "Text"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip\n  got : %v\n  want: %v", got, want)
+	}
+}
@@ -0,0 +1,251 @@
+package tm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"hugotranslationstudy/internal/subtokenize"
+)
+
+// WriteTMX renders entries as a TMX 1.4 document. Inline Markdown and HTML
+// markup inside a segment, as found by subtokenize.Subtokenize, becomes <ph>.
+func WriteTMX(w io.Writer, entries []Entry) error {
+	srcLang := "*all*"
+	for i, e := range entries {
+		if i == 0 {
+			srcLang = e.SourceLocale
+		} else if e.SourceLocale != srcLang {
+			srcLang = "*all*"
+			break
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	bw.WriteString("<tmx version=\"1.4\">\n")
+	fmt.Fprintf(bw, "  <header creationtool=\"hugotranslationstudy\" creationtoolversion=\"1\" segtype=\"phrase\" o-tmf=\"jsonl\" adminlang=\"en\" srclang=\"%s\" datatype=\"markdown\"/>\n",
+		escapeXML(srcLang))
+	bw.WriteString("  <body>\n")
+	for _, e := range entries {
+		bw.WriteString("    <tu>\n")
+		fmt.Fprintf(bw, "      <tuv xml:lang=\"%s\"><seg>%s</seg></tuv>\n", escapeXML(e.SourceLocale), segXML(e.Source))
+		fmt.Fprintf(bw, "      <tuv xml:lang=\"%s\"><seg>%s</seg></tuv>\n", escapeXML(e.TargetLocale), segXML(e.Target))
+		bw.WriteString("    </tu>\n")
+	}
+	bw.WriteString("  </body>\n")
+	bw.WriteString("</tmx>\n")
+	return bw.Flush()
+}
+
+// segXML renders text as <seg> content, with markup subtokens as <ph>.
+func segXML(text string) string {
+	subs, err := subtokenize.Subtokenize([]byte(text))
+	var joined strings.Builder
+	for _, s := range subs {
+		joined.WriteString(s.Val)
+	}
+	if err != nil || joined.String() != text {
+		return escapeXML(text)
+	}
+
+	// Only inline markup is a code. Taken on its own, a text subtoken can
+	// look like a block ("1. Intro" as a list item), and that must stay text
+	// or the key would change on the way back in.
+	pairs := subtokenize.Pairs(subs)
+	var b strings.Builder
+	x := 0
+	for i, s := range subs {
+		if s.Type == "markup" && (pairs[i] >= 0 || strings.HasPrefix(s.Val, "<")) {
+			x++
+			fmt.Fprintf(&b, "<ph x=\"%d\">%s</ph>", x, escapeXML(s.Val))
+		} else {
+			b.WriteString(escapeXML(s.Val))
+		}
+	}
+	return b.String()
+}
+
+func escapeXML(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+/* -------------------------------- Reading -------------------------------- */
+
+// ReadTMX reads the translation units of a TMX document. Every target
+// variant of a unit becomes an entry against the variant in the header's
+// srclang (or the first variant when srclang is "*all*").
+//
+// Inline codes (<ph>, <bpt>, <ept>, <it>, <ut>) split a segment into text
+// pieces, which are paired up with the target's pieces by position so that
+// entries are keyed on text subtokens. Units whose source and target have a
+// different number of pieces are skipped and counted.
+func ReadTMX(r io.Reader) (entries []Entry, skipped int, err error) {
+	dec := xml.NewDecoder(r)
+	srcLang := "*all*"
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return entries, skipped, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "header":
+			if l := attr(se, "srclang"); l != "" {
+				srcLang = l
+			}
+		case "tu":
+			tuvs, err := readTU(dec)
+			if err != nil {
+				return nil, 0, err
+			}
+			es, ok := pairTU(tuvs, srcLang)
+			if !ok {
+				skipped++
+			}
+			entries = append(entries, es...)
+		}
+	}
+}
+
+// tuv is one language variant of a translation unit, split into text pieces.
+type tuv struct {
+	lang   string
+	pieces []string
+}
+
+func readTU(dec *xml.Decoder) ([]tuv, error) {
+	var out []tuv
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "tuv":
+				out = append(out, tuv{lang: attr(t, "lang")})
+			case "seg":
+				if len(out) == 0 {
+					return nil, fmt.Errorf("<seg> outside <tuv>")
+				}
+				pieces, err := readSeg(dec)
+				if err != nil {
+					return nil, err
+				}
+				out[len(out)-1].pieces = pieces
+			default:
+				// <prop>, <note> and the like
+				if err := dec.Skip(); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "tu" {
+				return out, nil
+			}
+		}
+	}
+}
+
+// readSeg returns the trimmed, non-blank text pieces of a <seg>.
+func readSeg(dec *xml.Decoder) ([]string, error) {
+	var pieces []string
+	var cur strings.Builder
+	cut := func() {
+		if p := strings.TrimSpace(cur.String()); p != "" {
+			pieces = append(pieces, p)
+		}
+		cur.Reset()
+	}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			cur.Write(t)
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "hi":
+				// Highlighting wraps translatable text; keep reading it
+			default:
+				// Native code (or a <sub> flow) separates text pieces
+				cut()
+				if err := dec.Skip(); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "seg" {
+				cut()
+				return pieces, nil
+			}
+		}
+	}
+}
+
+func pairTU(tuvs []tuv, srcLang string) ([]Entry, bool) {
+	src := -1
+	for i, v := range tuvs {
+		if srcLang == "*all*" || strings.EqualFold(v.lang, srcLang) {
+			src = i
+			break
+		}
+	}
+	if src < 0 {
+		return nil, false
+	}
+
+	var out []Entry
+	ok := true
+	for i, v := range tuvs {
+		if i == src {
+			continue
+		}
+		if len(v.pieces) != len(tuvs[src].pieces) {
+			ok = false
+			continue
+		}
+		for j, p := range v.pieces {
+			out = append(out, Entry{
+				SourceLocale: tuvs[src].lang,
+				TargetLocale: v.lang,
+				Source:       tuvs[src].pieces[j],
+				Target:       p,
+			})
+		}
+	}
+	return out, ok
+}
+
+// LocaleMatches reports whether a TMX language code such as "en-US" belongs
+// to locale, either exactly or as a region of it (case-insensitive).
+func LocaleMatches(lang, locale string) bool {
+	lang, locale = strings.ToLower(lang), strings.ToLower(locale)
+	return lang == locale || strings.HasPrefix(lang, locale+"-")
+}
+
+func attr(se xml.StartElement, name string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,281 @@
+package tomarkdoc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"hugotranslationstudy/internal/frontmatter"
+
+	"github.com/gohugoio/hugo/parser/metadecoders"
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatterRule is one step of the front matter transform for the mdoc
+// output. Exactly one of Rename, Drop and Set is given; paths are
+// dot-separated and match case-insensitively.
+type FrontMatterRule struct {
+	// Rename moves the value at this path to To, e.g. linkTitle -> navTitle
+	// or menu.main.weight -> nav.order.
+	Rename string `yaml:"rename" json:"rename,omitempty"`
+	To     string `yaml:"to" json:"to,omitempty"`
+
+	// Drop removes the value at this path.
+	Drop string `yaml:"drop" json:"drop,omitempty"`
+
+	// Set computes the value at this path from Value, a text/template that
+	// sees the front matter as transformed so far. The result is read as a
+	// YAML scalar, so "3" becomes a number.
+	Set   string `yaml:"set" json:"set,omitempty"`
+	Value string `yaml:"value" json:"value,omitempty"`
+}
+
+// validate checks that r is one well-formed rule.
+func (r FrontMatterRule) validate() error {
+	n := 0
+	for _, p := range []string{r.Rename, r.Drop, r.Set} {
+		if p != "" {
+			n++
+		}
+	}
+	switch {
+	case n != 1:
+		return fmt.Errorf("want exactly one of rename, drop and set")
+	case r.Rename != "" && r.To == "":
+		return fmt.Errorf("rename %s has no to", r.Rename)
+	case r.Set != "":
+		_, err := valueTemplate(r.Value)
+		return err
+	}
+	return nil
+}
+
+// TransformFrontMatter applies rules to a front matter block and returns it
+// as YAML, the front matter Markdoc reads, along with a note for every key
+// dropped or replaced by a rename and every value that could not be
+// computed. Mappings left empty by a rename or drop are removed too, with a
+// note. YAML keeps its key order and comments; TOML and JSON come out with
+// sorted keys. Without rules, or without front matter, the block is returned
+// as is.
+func TransformFrontMatter(fm *frontmatter.Document, rules []FrontMatterRule) ([]byte, []string, error) {
+	block := fm.Bytes()
+	if len(rules) == 0 || fm.Format == "" {
+		return block, nil, nil
+	}
+
+	content, err := fm.Content()
+	if err != nil {
+		return nil, nil, err
+	}
+	var doc yaml.Node
+	if fm.Format == frontmatter.YAML {
+		err = yaml.Unmarshal(content, &doc)
+	} else {
+		var m map[string]any
+		if m, err = metadecoders.Default.UnmarshalToMap(content, metadecoders.Format(fm.Format)); err == nil {
+			doc.Kind = yaml.DocumentNode
+			doc.Content = []*yaml.Node{{}}
+			err = doc.Content[0].Encode(m)
+		}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s front matter: %w", fm.Format, err)
+	}
+	if len(doc.Content) == 0 {
+		doc.Kind = yaml.DocumentNode
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("%s front matter is not a mapping", fm.Format)
+	}
+
+	var notes []string
+	emptied := func(paths []string) {
+		for _, p := range paths {
+			notes = append(notes, "dropped empty "+p)
+		}
+	}
+	for _, r := range rules {
+		switch {
+		case r.Rename != "":
+			if done, replaced := renameInPlace(root, r.Rename, r.To); done {
+				if replaced {
+					notes = append(notes, "replaced "+r.To)
+				}
+				continue
+			}
+			v, empty := removePath(root, r.Rename)
+			if v == nil {
+				continue
+			}
+			emptied(empty)
+			if replaced := setPath(root, r.To, v); replaced != "" {
+				notes = append(notes, "replaced "+replaced)
+			}
+		case r.Drop != "":
+			if v, empty := removePath(root, r.Drop); v != nil {
+				notes = append(notes, "dropped "+r.Drop)
+				emptied(empty)
+			}
+		case r.Set != "":
+			v, err := computeValue(root, r.Value)
+			if err != nil {
+				notes = append(notes, fmt.Sprintf("did not set %s: %v", r.Set, err))
+				continue
+			}
+			setPath(root, r.Set, v)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	if len(root.Content) > 0 {
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&doc); err != nil {
+			return nil, nil, err
+		}
+		enc.Close()
+	}
+	buf.WriteString("---\n")
+	return buf.Bytes(), notes, nil
+}
+
+// findKey returns the index of the key node matching name in mapping m, or -1.
+func findKey(m *yaml.Node, name string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if strings.EqualFold(m.Content[i].Value, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// renameInPlace renames the key at from when to only differs in its last
+// segment, so the key keeps its place. It reports whether it did, and
+// whether a value already at to was replaced.
+func renameInPlace(m *yaml.Node, from, to string) (done, replaced bool) {
+	fromDir, fromKey := splitLast(from)
+	toDir, toKey := splitLast(to)
+	if !strings.EqualFold(fromDir, toDir) {
+		return false, false
+	}
+	for _, seg := range strings.Split(fromDir, ".") {
+		if seg == "" {
+			break
+		}
+		i := findKey(m, seg)
+		if i < 0 || m.Content[i+1].Kind != yaml.MappingNode {
+			return true, false // nothing to rename
+		}
+		m = m.Content[i+1]
+	}
+	i := findKey(m, fromKey)
+	if i < 0 {
+		return true, false
+	}
+	if j := findKey(m, toKey); j >= 0 && j != i {
+		m.Content = append(m.Content[:j], m.Content[j+2:]...)
+		if j < i {
+			i -= 2
+		}
+		replaced = true
+	}
+	m.Content[i].Value = toKey
+	return true, replaced
+}
+
+func splitLast(path string) (dir, key string) {
+	if i := strings.LastIndexByte(path, '.'); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return "", path
+}
+
+// removePath removes the value at path from m and returns it, or nil if
+// there is none. Mappings that end up empty are removed as well; their
+// paths are returned, innermost first.
+func removePath(m *yaml.Node, path string) (*yaml.Node, []string) {
+	key, rest, nested := strings.Cut(path, ".")
+	i := findKey(m, key)
+	if i < 0 {
+		return nil, nil
+	}
+	if !nested {
+		v := m.Content[i+1]
+		m.Content = append(m.Content[:i], m.Content[i+2:]...)
+		return v, nil
+	}
+	child := m.Content[i+1]
+	if child.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	v, emptied := removePath(child, rest)
+	for j := range emptied {
+		emptied[j] = key + "." + emptied[j]
+	}
+	if v != nil && len(child.Content) == 0 {
+		m.Content = append(m.Content[:i], m.Content[i+2:]...)
+		emptied = append(emptied, key)
+	}
+	return v, emptied
+}
+
+// setPath puts v at path in m, creating mappings on the way. A value that
+// is already there, or a value on the way that is not a mapping, is
+// replaced; setPath returns its path, or "" if nothing was replaced.
+func setPath(m *yaml.Node, path string, v *yaml.Node) string {
+	key, rest, nested := strings.Cut(path, ".")
+	i := findKey(m, key)
+	replaced := ""
+	switch {
+	case i < 0:
+		m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"})
+		i = len(m.Content) - 2
+	case !nested || m.Content[i+1].Kind != yaml.MappingNode:
+		replaced = key
+	}
+	if !nested {
+		m.Content[i+1] = v
+		return replaced
+	}
+	if m.Content[i+1].Kind != yaml.MappingNode {
+		m.Content[i+1] = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	if r := setPath(m.Content[i+1], rest, v); r != "" {
+		return key + "." + r
+	}
+	return replaced
+}
+
+// computeValue runs the template tmpl over the front matter in root.
+// Referring to a key that is not there is an error.
+func computeValue(root *yaml.Node, tmpl string) (*yaml.Node, error) {
+	t, err := valueTemplate(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]any
+	if err := root.Decode(&data); err != nil {
+		return nil, err
+	}
+	var out strings.Builder
+	if err := t.Execute(&out, data); err != nil {
+		return nil, err
+	}
+	var v yaml.Node
+	if err := yaml.Unmarshal([]byte(out.String()), &v); err != nil || len(v.Content) == 0 || v.Content[0].Kind != yaml.ScalarNode {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: out.String()}, nil
+	}
+	return v.Content[0], nil
+}
+
+func valueTemplate(tmpl string) (*template.Template, error) {
+	return template.New("value").Option("missingkey=error").Funcs(template.FuncMap{
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+		"trim":  strings.TrimSpace,
+	}).Parse(tmpl)
+}
@@ -0,0 +1,165 @@
+package tomarkdoc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Report sums up a migration: the shortcodes of every page and of the site
+// as a whole, with what could not be converted cleanly. It is written as
+// JSON and rendered as Markdown.
+type Report struct {
+	Files  []FileReport     `json:"files"`
+	Totals []ShortcodeStats `json:"totals"`
+}
+
+// FileReport is the part of a Report about one page.
+type FileReport struct {
+	Source      string           `json:"source"`
+	Shortcodes  []ShortcodeStats `json:"shortcodes"`
+	Warnings    []Warning        `json:"warnings,omitempty"`
+	FrontMatter []string         `json:"frontMatter,omitempty"` // notes from the front matter transform
+}
+
+// ShortcodeStats counts the uses of one shortcode.
+type ShortcodeStats struct {
+	Name       string   `json:"name"`
+	Count      int      `json:"count"`
+	Paired     int      `json:"paired"`
+	Standalone int      `json:"standalone"`
+	Markup     int      `json:"markup"` // written with {{% %}}
+	Params     []string `json:"params,omitempty"`
+	Warnings   int      `json:"warnings"`
+}
+
+// Warning is a Diagnostic placed on a line of the source file.
+type Warning struct {
+	Line      int    `json:"line"`
+	Shortcode string `json:"shortcode,omitempty"`
+	Message   string `json:"message"`
+}
+
+// Add adds the outcome of converting one page to r. line maps a body
+// offset to a line of the source file; notes are the front matter notes.
+func (r *Report) Add(source string, res Result, line func(offset int) int, notes []string) {
+	f := FileReport{Source: source, Shortcodes: []ShortcodeStats{}, FrontMatter: notes}
+	stats := map[string]*ShortcodeStats{}
+	get := func(name string) *ShortcodeStats {
+		if stats[name] == nil {
+			stats[name] = &ShortcodeStats{Name: name}
+		}
+		return stats[name]
+	}
+	for _, u := range res.Shortcodes {
+		s := get(u.Shortcode)
+		s.Count++
+		if u.Paired {
+			s.Paired++
+		} else {
+			s.Standalone++
+		}
+		if u.Markup {
+			s.Markup++
+		}
+		s.Params = mergeNames(s.Params, u.Params)
+	}
+	for _, d := range res.Diagnostics {
+		f.Warnings = append(f.Warnings, Warning{Line: line(d.Offset), Shortcode: d.Shortcode, Message: d.Message})
+		if d.Shortcode != "" {
+			get(d.Shortcode).Warnings++
+		}
+	}
+	for _, s := range stats {
+		f.Shortcodes = append(f.Shortcodes, *s)
+	}
+	sortStats(f.Shortcodes)
+	r.Files = append(r.Files, f)
+
+	for _, s := range f.Shortcodes {
+		i := sort.Search(len(r.Totals), func(i int) bool { return r.Totals[i].Name >= s.Name })
+		if i == len(r.Totals) || r.Totals[i].Name != s.Name {
+			r.Totals = append(r.Totals, ShortcodeStats{})
+			copy(r.Totals[i+1:], r.Totals[i:])
+			r.Totals[i] = ShortcodeStats{Name: s.Name}
+		}
+		t := &r.Totals[i]
+		t.Count += s.Count
+		t.Paired += s.Paired
+		t.Standalone += s.Standalone
+		t.Markup += s.Markup
+		t.Warnings += s.Warnings
+		t.Params = mergeNames(t.Params, s.Params)
+	}
+}
+
+// Markdown renders r as a Markdown document: the totals first, then a
+// section per page.
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	count := 0
+	for _, s := range r.Totals {
+		count += s.Count
+	}
+	b.WriteString("# Markdoc migration report\n\n")
+	fmt.Fprintf(&b, "%d file(s), %d shortcode(s), %d distinct.\n\n", len(r.Files), count, len(r.Totals))
+	b.WriteString("## All files\n\n")
+	writeStatsTable(&b, r.Totals)
+
+	for _, f := range r.Files {
+		fmt.Fprintf(&b, "\n## %s\n\n", f.Source)
+		writeStatsTable(&b, f.Shortcodes)
+		if len(f.Warnings) > 0 {
+			b.WriteString("\nWarnings:\n\n")
+			for _, w := range f.Warnings {
+				if w.Shortcode != "" {
+					fmt.Fprintf(&b, "- line %d: %s: %s\n", w.Line, w.Shortcode, w.Message)
+				} else {
+					fmt.Fprintf(&b, "- line %d: %s\n", w.Line, w.Message)
+				}
+			}
+		}
+		if len(f.FrontMatter) > 0 {
+			b.WriteString("\nFront matter:\n\n")
+			for _, n := range f.FrontMatter {
+				fmt.Fprintf(&b, "- %s\n", n)
+			}
+		}
+	}
+	return b.String()
+}
+
+func writeStatsTable(b *strings.Builder, stats []ShortcodeStats) {
+	if len(stats) == 0 {
+		b.WriteString("No shortcodes.\n")
+		return
+	}
+	b.WriteString("| Shortcode | Count | Paired | Standalone | `{{% %}}` | Params | Warnings |\n")
+	b.WriteString("|---|--:|--:|--:|--:|---|--:|\n")
+	for _, s := range stats {
+		params := make([]string, len(s.Params))
+		for i, p := range s.Params {
+			params[i] = "`" + p + "`"
+		}
+		fmt.Fprintf(b, "| `%s` | %d | %d | %d | %d | %s | %d |\n",
+			s.Name, s.Count, s.Paired, s.Standalone, s.Markup, strings.Join(params, ", "), s.Warnings)
+	}
+}
+
+func sortStats(stats []ShortcodeStats) {
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+}
+
+// mergeNames adds the names in add to the sorted set names.
+func mergeNames(names, add []string) []string {
+	for _, n := range add {
+		i := sort.SearchStrings(names, n)
+		if i < len(names) && names[i] == n {
+			continue
+		}
+		names = append(names, "")
+		copy(names[i+1:], names[i:])
+		names[i] = n
+	}
+	return names
+}
@@ -0,0 +1,115 @@
+package tomarkdoc
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schema describes how Hugo shortcodes map onto Markdoc tags.
+type Schema struct {
+	Shortcodes map[string]ShortcodeSchema `yaml:"shortcodes" json:"shortcodes"`
+
+	// MarkupAttribute, if set, is added as name=true to the tags of
+	// shortcodes written with {{% %}}, so the notation survives the
+	// migration (and the way back).
+	MarkupAttribute string `yaml:"markupAttribute" json:"markupAttribute,omitempty"`
+
+	// FrontMatter transforms the front matter of the mdoc output, rule by
+	// rule. Without rules it is copied as is.
+	FrontMatter []FrontMatterRule `yaml:"frontMatter" json:"frontMatter,omitempty"`
+}
+
+// ShortcodeSchema is the Markdoc mapping of one shortcode.
+type ShortcodeSchema struct {
+	// Positional names the attribute for each positional parameter, e.g.
+	// [text] turns {{< note "Hi" >}} into {% note text="Hi" /%}.
+	Positional []string `yaml:"positional" json:"positional,omitempty"`
+
+	// Tag renames the shortcode, e.g. note -> callout.
+	Tag string `yaml:"tag" json:"tag,omitempty"`
+
+	// Attributes renames parameters, e.g. {type: kind}.
+	Attributes map[string]string `yaml:"attributes" json:"attributes,omitempty"`
+
+	// Defaults are attributes added when the shortcode does not set them.
+	Defaults map[string]any `yaml:"defaults" json:"defaults,omitempty"`
+
+	// Drop removes the shortcode. The content of a paired shortcode stays.
+	Drop bool `yaml:"drop" json:"drop,omitempty"`
+
+	// Notation is the Hugo delimiter the shortcode is written with when
+	// converting back from Markdoc: "<" for {{< >}} (the default) or "%" for
+	// {{% %}}.
+	Notation string `yaml:"notation" json:"notation,omitempty"`
+
+	// Markdown, if set, replaces the shortcode with plain Markdown. It is a
+	// text/template that sees the parameters by name and, for a paired
+	// shortcode, the converted content as .Inner, e.g. "![{{.alt}}]({{.src}})".
+	// The function trim strips surrounding whitespace.
+	Markdown string `yaml:"markdown" json:"markdown,omitempty"`
+}
+
+// LoadSchema reads a schema file, YAML or JSON.
+func LoadSchema(path string) (Schema, error) {
+	var s Schema
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	if err := yaml.Unmarshal(b, &s); err != nil {
+		return s, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for name, sc := range s.Shortcodes {
+		if sc.Markdown == "" {
+			continue
+		}
+		if _, err := sc.template(); err != nil {
+			return s, fmt.Errorf("%s: shortcode %s: %w", path, name, err)
+		}
+	}
+	for i, r := range s.FrontMatter {
+		if err := r.validate(); err != nil {
+			return s, fmt.Errorf("%s: front matter rule %d: %w", path, i+1, err)
+		}
+	}
+	return s, nil
+}
+
+// positionalName returns the attribute name for the positional parameter at
+// pos of shortcode, or "".
+func (s Schema) positionalName(shortcode string, pos int) string {
+	p := s.Shortcodes[shortcode].Positional
+	if pos < len(p) {
+		return p[pos]
+	}
+	return ""
+}
+
+// Tag returns the Markdoc tag name for shortcode.
+func (s Schema) Tag(shortcode string) string {
+	if t := s.Shortcodes[shortcode].Tag; t != "" {
+		return t
+	}
+	return shortcode
+}
+
+// attribute returns the Markdoc attribute name for parameter param of
+// shortcode.
+func (s Schema) attribute(shortcode, param string) string {
+	if a, ok := s.Shortcodes[shortcode].Attributes[param]; ok && a != "" {
+		return a
+	}
+	return param
+}
+
+// template parses the Markdown template. Missing parameters are errors, so a
+// shortcode that lacks one is kept as a tag instead of rendering "<no value>".
+func (sc ShortcodeSchema) template() (*template.Template, error) {
+	return template.New("markdown").Option("missingkey=error").Funcs(template.FuncMap{
+		"trim": strings.TrimSpace,
+	}).Parse(sc.Markdown)
+}
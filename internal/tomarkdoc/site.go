@@ -0,0 +1,223 @@
+package tomarkdoc
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Site indexes the pages of a content tree so that ref and relref
+// shortcodes can be turned into links. Pages are found the way Hugo finds
+// them: by path from the content root, by path from the referring page, by
+// file name when it is unique, and by section. Aliases lead to the page
+// that declares them.
+//
+// A page named like post.fr.md, for one of the site's languages, belongs to
+// that language and is found as post.md from pages of the same language.
+// Pages of other languages than the default one live under /<lang>/.
+type Site struct {
+	baseURL     string              // prefix of the URLs ref gives; relref gives paths
+	defaultLang string              // language of pages without a language suffix
+	langs       map[string]bool     // languages a file name may end in
+	urls        map[string]string   // language and lookup key -> URL path
+	names       map[string][]string // language and file name -> keys of the pages that have it
+}
+
+// NewSite returns an empty Site. baseURL is the site's baseURL, which may
+// be empty; defaultLang is the language of the content, and langs are all
+// the site's languages.
+func NewSite(baseURL, defaultLang string, langs []string) *Site {
+	s := &Site{
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		defaultLang: strings.ToLower(defaultLang),
+		langs:       map[string]bool{},
+		urls:        map[string]string{},
+		names:       map[string][]string{},
+	}
+	for _, l := range append(langs, defaultLang) {
+		if l != "" {
+			s.langs[strings.ToLower(l)] = true
+		}
+	}
+	return s
+}
+
+// splitLang splits the language suffix off a file name without extension,
+// post.fr -> post, fr. Names without a known suffix are in the default
+// language.
+func (s *Site) splitLang(name string) (string, string) {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 && s.langs[name[i+1:]] {
+		return name[:i], name[i+1:]
+	}
+	return name, s.defaultLang
+}
+
+// langPrefix returns what the URLs of pages in lang start with.
+func (s *Site) langPrefix(lang string) string {
+	if lang == s.defaultLang {
+		return ""
+	}
+	return "/" + lang
+}
+
+// key scopes a lookup key to a language.
+func key(lang, k string) string {
+	return lang + ":" + k
+}
+
+// AddPage adds the page at file, a slash-separated path from the content
+// root, with its front matter. The URL is the front matter url if set,
+// otherwise the folder of the page followed by its slug or file name;
+// index.md and _index.md stand for their folder.
+func (s *Site) AddPage(file string, fm map[string]any) {
+	file = strings.ToLower(path.Clean(strings.TrimPrefix(file, "/")))
+	dir, base := path.Split(file)
+	dir = strings.TrimSuffix(dir, "/")
+	ext := path.Ext(base)
+	name, lang := s.splitLang(strings.TrimSuffix(base, ext))
+	slug, _ := frontMatterString(fm, "slug")
+	slug = strings.ToLower(slug)
+
+	page := path.Join(dir, name) // blog/post, blog/_index, blog/post/index
+	var url string
+	switch name {
+	case "_index":
+		page, url = dir, urlPath(dir)
+	case "index":
+		page = dir
+		if slug != "" {
+			url = urlPath(path.Join(path.Dir(dir), slug))
+		} else {
+			url = urlPath(dir)
+		}
+	default:
+		if slug == "" {
+			slug = name
+		}
+		url = urlPath(path.Join(dir, slug))
+	}
+	url = s.langPrefix(lang) + url
+	if u, ok := frontMatterString(fm, "url"); ok && u != "" {
+		url = "/" + strings.TrimPrefix(u, "/")
+	}
+
+	s.urls[key(lang, page)] = url
+	s.urls[key(lang, path.Join(dir, name+ext))] = url
+	s.urls[key(lang, file)] = url
+	if name != "_index" && name != "index" {
+		s.names[key(lang, name)] = append(s.names[key(lang, name)], page)
+	}
+	var aliases []string
+	switch v := frontMatterValue(fm, "aliases").(type) {
+	case []string:
+		aliases = v
+	case []any:
+		for _, a := range v {
+			if a, ok := a.(string); ok {
+				aliases = append(aliases, a)
+			}
+		}
+	}
+	for _, a := range aliases {
+		s.urls[key(lang, "@"+strings.ToLower(strings.Trim(a, "/")))] = url
+	}
+	// Hugo lists the pages of every top-level folder at /folder/.
+	if section, _, _ := strings.Cut(dir, "/"); section != "" {
+		if _, ok := s.urls[key(lang, section)]; !ok {
+			s.urls[key(lang, section)] = s.langPrefix(lang) + urlPath(section)
+		}
+	}
+}
+
+// Resolve returns the link a ref (absolute) or relref shortcode in the page
+// at from gives for target, e.g. "../post.md#usage".
+func (s *Site) Resolve(target, from string, absolute bool) (string, error) {
+	ref, anchor, _ := strings.Cut(target, "#")
+	if anchor != "" {
+		anchor = "#" + anchor
+	}
+	from = strings.ToLower(path.Clean(strings.TrimPrefix(from, "/")))
+	url, err := s.lookup(strings.ToLower(ref), from)
+	if err != nil {
+		return "", err
+	}
+	if absolute {
+		url = s.baseURL + url
+	}
+	return url + anchor, nil
+}
+
+// lookup finds ref in the language of the page at from, then in the
+// default language.
+func (s *Site) lookup(ref, from string) (string, error) {
+	if ref == "" {
+		ref = "/" + from // the page itself
+	}
+	base := path.Base(from)
+	_, lang := s.splitLang(strings.TrimSuffix(base, path.Ext(base)))
+	url, err := s.lookupIn(lang, ref, from)
+	if errors.Is(err, errNoPage) && lang != s.defaultLang {
+		url, err = s.lookupIn(s.defaultLang, ref, from)
+	}
+	return url, err
+}
+
+var errNoPage = errors.New("no such page")
+
+func (s *Site) lookupIn(lang, ref, from string) (string, error) {
+	clean := func(p string) string {
+		p = strings.Trim(path.Clean(p), "/")
+		if p == "." {
+			return ""
+		}
+		return p
+	}
+	var keys []string
+	if !strings.HasPrefix(ref, "/") {
+		keys = append(keys, clean(path.Join(path.Dir(from), ref)))
+	}
+	keys = append(keys, clean(ref), "@"+clean(ref))
+	for _, k := range keys {
+		if url, ok := s.urls[key(lang, k)]; ok {
+			return url, nil
+		}
+	}
+	if !strings.Contains(strings.Trim(ref, "/"), "/") {
+		name := strings.TrimSuffix(clean(ref), ".md")
+		switch found := s.names[key(lang, name)]; len(found) {
+		case 0:
+		case 1:
+			return s.urls[key(lang, found[0])], nil
+		default:
+			sort.Strings(found)
+			return "", fmt.Errorf("ambiguous, it matches %s", strings.Join(found, ", "))
+		}
+	}
+	return "", errNoPage
+}
+
+// urlPath turns a content path into the URL path Hugo gives it.
+func urlPath(p string) string {
+	if p == "" || p == "." {
+		return "/"
+	}
+	return "/" + strings.ReplaceAll(p, " ", "-") + "/"
+}
+
+// frontMatterValue looks up a top-level front matter key, ignoring case as
+// Hugo does.
+func frontMatterValue(fm map[string]any, key string) any {
+	for k, v := range fm {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+func frontMatterString(fm map[string]any, key string) (string, bool) {
+	s, ok := frontMatterValue(fm, key).(string)
+	return s, ok
+}
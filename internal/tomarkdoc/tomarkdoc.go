@@ -2,12 +2,17 @@ package tomarkdoc
 
 import (
 	"bytes"
+	"fmt"
 	"log"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
+	"hugotranslationstudy/internal/subtokenize"
+
 	"github.com/gohugoio/hugo/parser/pageparser"
-	"gopkg.in/yaml.v3"
 )
 
 // Tok is a tiny wrapper around pageparser items that we care about.
@@ -16,12 +21,72 @@ type Tok struct {
 	Val   []byte
 	Start int
 	End   int
+	Value any // typed value of a shortcode parameter: string, bool, int or float64
+}
+
+// Diagnostic reports a shortcode that could not be converted cleanly.
+type Diagnostic struct {
+	Offset    int    // byte offset of the shortcode in the body
+	Shortcode string // shortcode name
+	Message   string
+}
+
+// Result is the outcome of converting one body.
+type Result struct {
+	Body        string
+	Diagnostics []Diagnostic // what could not be converted cleanly
+	Review      []Diagnostic // converted, but worth a manual look
+	Shortcodes  []Use        // every shortcode met, in order
+}
+
+// Use is one shortcode as it appears in the Hugo body.
+type Use struct {
+	Offset    int
+	Shortcode string
+	Paired    bool     // has a closing tag
+	Markup    bool     // written with {{% %}}
+	Params    []string // parameter names; positional ones by index, "0", "1", ...
 }
 
 // Public entry point: convert a Hugo body to .mdoc shortcode punctuation.
+// Shortcodes inside code blocks and code spans are left as they are.
 func ConvertBodyToMdocTokens(body string) string {
+	return Convert(body, Schema{}).Body
+}
+
+// Convert turns a Hugo body into Markdoc. Shortcode parameters become
+// attributes with typed values; positional parameters are named through
+// schema. Anything that could not be mapped is reported, and {{% %}}
+// shortcodes with content are listed for review.
+func Convert(body string, schema Schema) Result {
+	return ConvertPage(body, schema, nil, "")
+}
+
+// ConvertPage is Convert for the page at file, a slash-separated path from
+// the content root. With a site, ref and relref shortcodes become the links
+// they stand for; those that cannot be resolved are kept as they are and
+// reported.
+func ConvertPage(body string, schema Schema, site *Site, file string) Result {
 	toks := tokenizeShortcodes(body)
-	return renderToMdoc(toks, body)
+	toks = maskCode(toks, body, subtokenize.CodeRanges([]byte(body)))
+	c := &converter{schema: schema, site: site, file: file, closes: map[int]bool{}}
+	out := renderToMdoc(c, toks, body)
+	return Result{Body: out, Diagnostics: c.diags, Review: c.review, Shortcodes: c.uses}
+}
+
+// converter carries the schema and collects diagnostics during rendering.
+type converter struct {
+	schema Schema
+	site   *Site        // resolves ref and relref, if set
+	file   string       // the page, for relative refs
+	closes map[int]bool // offsets of the closing tags that have an opening
+	diags  []Diagnostic
+	review []Diagnostic
+	uses   []Use
+}
+
+func (c *converter) report(offset int, shortcode, format string, args ...any) {
+	c.diags = append(c.diags, Diagnostic{Offset: offset, Shortcode: shortcode, Message: fmt.Sprintf(format, args...)})
 }
 
 /* ------------------------------- Tokenizing ------------------------------- */
@@ -37,21 +102,78 @@ func tokenizeShortcodes(body string) []Tok {
 	var toks []Tok
 	for {
 		item := it.Next()
+		if item.IsError() {
+			return recoverText(toks, body, item.Pos(), item.Err.Error())
+		}
 		if item.IsEOF() || item.IsDone() {
 			break
 		}
 		start := item.Pos()
 		val := item.Val(src)
-		toks = append(toks, Tok{
+		tok := Tok{
 			Typ:   item.Type.String(),
 			Val:   val,
 			Start: start,
 			End:   start + len(val),
-		})
+		}
+		if item.IsShortcodeParam() || item.IsShortcodeParamVal() {
+			tok.Value = item.ValTyped(src)
+		}
+		toks = append(toks, tok)
+	}
+	for i := len(toks) - 1; i >= 0 && !isRightDelim(toks[i].Typ); i-- {
+		if isLeftDelim(toks[i].Typ) {
+			return recoverText(toks, body, toks[i].Start, "unterminated shortcode")
+		}
 	}
 	return toks
 }
 
+// recoverText handles a body pageparser gave up on at pos: everything from
+// the shortcode being lexed there on becomes plain text, after a tError
+// token carrying msg.
+func recoverText(toks []Tok, body string, pos int, msg string) []Tok {
+	for i := len(toks) - 1; i >= 0 && !isRightDelim(toks[i].Typ); i-- {
+		if isLeftDelim(toks[i].Typ) {
+			pos = toks[i].Start
+			break
+		}
+	}
+	for len(toks) > 0 && toks[len(toks)-1].Start >= pos {
+		toks = toks[:len(toks)-1]
+	}
+	return append(toks,
+		Tok{Typ: "tError", Val: []byte(msg), Start: pos, End: pos},
+		Tok{Typ: "tText", Val: []byte(body[pos:]), Start: pos, End: len(body)})
+}
+
+// maskCode turns every shortcode that starts inside a code range into plain
+// text, from its left delimiter through its right delimiter, so it is
+// neither rendered nor paired with a closing tag outside the code.
+func maskCode(toks []Tok, body string, code []subtokenize.Range) []Tok {
+	inCode := func(pos int) bool {
+		i := sort.Search(len(code), func(i int) bool { return code[i].Stop > pos })
+		return i < len(code) && code[i].Start <= pos
+	}
+
+	out := make([]Tok, 0, len(toks))
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		if isLeftDelim(t.Typ) && inCode(t.Start) {
+			j := i + 1
+			for j < len(toks) && !isRightDelim(toks[j].Typ) {
+				j++
+			}
+			if j < len(toks) {
+				t = Tok{Typ: "tText", Val: []byte(body[t.Start:toks[j].End]), Start: t.Start, End: toks[j].End}
+				i = j
+			}
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
 func isLeftDelim(typ string) bool {
 	return typ == "tLeftDelimScNoMarkup" || typ == "tLeftDelimScWithMarkup"
 }
@@ -88,9 +210,10 @@ func getInterior(toks []Tok, body string, leftIdx int) (interior, name string, r
 	return trimmed, name, j
 }
 
-// hasMatchingClose checks if, after the right delimiter of an opening tag,
-// a matching closing tag for the given name occurs (nesting-aware).
-func hasMatchingClose(toks []Tok, body string, fromRightIdx int, name string) bool {
+// matchingClose returns the index of the left delimiter of the closing tag
+// that matches an opening tag of the given name whose right delimiter is at
+// fromRightIdx (nesting-aware), or -1 for a standalone shortcode.
+func matchingClose(toks []Tok, body string, fromRightIdx int, name string) int {
 	depth := 0
 	for i := fromRightIdx + 1; i < len(toks); i++ {
 		if isLeftDelim(toks[i].Typ) {
@@ -99,7 +222,7 @@ func hasMatchingClose(toks []Tok, body string, fromRightIdx int, name string) bo
 				_, closeName, rIdx := getInterior(toks, body, i)
 				if closeName == name {
 					if depth == 0 {
-						return true
+						return i
 					}
 					depth--
 				}
@@ -108,18 +231,24 @@ func hasMatchingClose(toks []Tok, body string, fromRightIdx int, name string) bo
 			}
 			// Opening of the same name (nested)
 			_, openName, rIdx := getInterior(toks, body, i)
-			if openName == name {
+			if openName == name && !selfClosing(toks, i, rIdx) {
 				depth++
 			}
 			i = rIdx
 		}
 	}
-	return false
+	return -1
+}
+
+// selfClosing reports whether the shortcode between leftIdx and rightIdx is
+// written self-closing, {{< name />}}.
+func selfClosing(toks []Tok, leftIdx, rightIdx int) bool {
+	return rightIdx > leftIdx+1 && toks[rightIdx-1].Typ == "tScClose"
 }
 
 /* -------------------------------- Rendering ------------------------------- */
 
-func renderToMdoc(toks []Tok, body string) string {
+func renderToMdoc(c *converter, toks []Tok, body string) string {
 	var out strings.Builder
 
 	for i := 0; i < len(toks); i++ {
@@ -129,14 +258,17 @@ func renderToMdoc(toks []Tok, body string) string {
 		case t.Typ == "tText":
 			out.Write(t.Val)
 
+		case t.Typ == "tError":
+			c.report(t.Start, "", "Hugo cannot parse the page from here (%s); the rest is kept as is", t.Val)
+
 		case isLeftDelim(t.Typ):
 			// Closing shortcode?
 			if i+1 < len(toks) && toks[i+1].Typ == "tScClose" {
-				writeClosingShortcode(&out, toks, body, &i)
+				writeClosingShortcode(c, &out, toks, body, &i)
 				continue
 			}
 			// Opening shortcode (paired vs standalone)
-			writeOpeningShortcode(&out, toks, body, &i)
+			writeOpeningShortcode(c, &out, toks, body, &i)
 
 		case isRightDelim(t.Typ):
 			// Right delimiters are consumed by left handlers; ignore stray.
@@ -150,19 +282,26 @@ func renderToMdoc(toks []Tok, body string) string {
 	return out.String()
 }
 
-func writeClosingShortcode(out *strings.Builder, toks []Tok, body string, i *int) {
+func writeClosingShortcode(c *converter, out *strings.Builder, toks []Tok, body string, i *int) {
 	_, name, rIdx := getInterior(toks, body, *i)
-	if name == "" {
-		out.WriteString("{% / %}")
-	} else {
-		out.WriteString("{% /")
-		out.WriteString(name)
-		out.WriteString(" %}")
-	}
+	offset := toks[*i].Start
+	closes := c.closes[offset]
 	*i = rIdx // advance past the right delimiter we consumed
+	if !closes {
+		// Hugo itself rejects these; leave them out rather than emit an
+		// unbalanced tag.
+		c.report(offset, name, "closing shortcode without an opening one; left out")
+		return
+	}
+	if c.schema.Shortcodes[name].Drop {
+		return
+	}
+	out.WriteString("{% /")
+	out.WriteString(c.schema.Tag(name))
+	out.WriteString(" %}")
 }
 
-func writeOpeningShortcode(out *strings.Builder, toks []Tok, body string, i *int) {
+func writeOpeningShortcode(c *converter, out *strings.Builder, toks []Tok, body string, i *int) {
 	interior, name, rIdx := getInterior(toks, body, *i)
 	trimmed := strings.TrimSpace(interior)
 
@@ -175,30 +314,267 @@ func writeOpeningShortcode(out *strings.Builder, toks []Tok, body string, i *int
 		return
 	}
 
-	if hasMatchingClose(toks, body, rIdx, name) {
+	offset := toks[*i].Start
+	closeIdx := -1
+	if !selfClosing(toks, *i, rIdx) {
+		if closeIdx = matchingClose(toks, body, rIdx, name); closeIdx >= 0 {
+			c.closes[toks[closeIdx].Start] = true
+		}
+	}
+	c.uses = append(c.uses, Use{Offset: offset, Shortcode: name, Paired: closeIdx >= 0,
+		Markup: toks[*i].Typ == "tLeftDelimScWithMarkup", Params: paramNames(toks[*i+1 : rIdx])})
+
+	if toks[*i+1].Typ == "tScNameInline" {
+		// Inline shortcodes carry Go template code; there is nothing to
+		// turn them into.
+		end := rIdx
+		if closeIdx >= 0 {
+			_, _, end = getInterior(toks, body, closeIdx)
+		}
+		c.report(offset, name, "inline shortcodes have no Markdoc equivalent; kept as is")
+		out.WriteString(body[offset:toks[end].End])
+		*i = end
+		return
+	}
+
+	if (name == "ref" || name == "relref") && c.site != nil {
+		out.WriteString(c.ref(toks[*i+1:rIdx], name == "ref", body[offset:toks[rIdx].End], name, offset))
+		*i = rIdx
+		return
+	}
+
+	sc := c.schema.Shortcodes[name]
+	params := c.params(toks[*i+1:rIdx], name, offset)
+	markup := toks[*i].Typ == "tLeftDelimScWithMarkup"
+	*i = rIdx
+
+	if sc.Drop {
+		return
+	}
+	if sc.Markdown != "" {
+		var inner []Tok
+		end := rIdx
+		if closeIdx >= 0 {
+			// The content and closing tag go into the Markdown as well.
+			inner = toks[rIdx+1 : closeIdx]
+			_, _, end = getInterior(toks, body, closeIdx)
+		}
+		if md, ok := c.markdown(sc, params, inner, closeIdx >= 0, body, name, offset); ok {
+			out.WriteString(md)
+			*i = end
+			return
+		}
+	}
+
+	attrs := c.attributes(params, name, offset)
+	if markup {
+		// Keep the {{% %}} notation: Hugo renders what such a shortcode
+		// produces as Markdown, which a Markdoc tag does not know about.
+		if a := c.schema.MarkupAttribute; a != "" && !hasAttribute(attrs, a) {
+			attrs = append(attrs, a+"=true")
+		}
+		if closeIdx >= 0 {
+			c.review = append(c.review, Diagnostic{Offset: offset, Shortcode: name,
+				Message: "{{% %}} content is rendered as Markdown in Hugo"})
+		}
+	}
+	out.WriteString("{% ")
+	out.WriteString(c.schema.Tag(name))
+	for _, attr := range attrs {
+		out.WriteString(" ")
+		out.WriteString(attr)
+	}
+	if closeIdx >= 0 {
 		// Paired shortcode
-		out.WriteString("{% ")
-		out.WriteString(trimmed)
 		out.WriteString(" %}")
 	} else {
 		// Standalone (self-closing in .mdoc)
-		out.WriteString("{% ")
-		out.WriteString(trimmed)
 		out.WriteString(" /%}")
 	}
-	*i = rIdx
 }
 
-func WriteMdocFile(outPath string, frontMatter map[string]any, body string) {
-	// Front matter identical (YAML fences)
-	fm, err := yaml.Marshal(frontMatter)
+// markdown renders the Markdown template of a shortcode, with the converted
+// inner tokens as .Inner if it is paired. It reports false, leaving no
+// diagnostics from the inner tokens behind, if the template fails.
+func (c *converter) markdown(sc ShortcodeSchema, params []param, inner []Tok, paired bool, body, name string, offset int) (string, bool) {
+	tmpl, err := sc.template()
+	if err != nil {
+		c.report(offset, name, "markdown template: %v", err)
+		return "", false
+	}
+	data := map[string]any{}
+	for _, p := range params {
+		if p.Name != "" {
+			data[p.Name] = p.Value
+		}
+	}
+	n, u := len(c.diags), len(c.uses)
+	if paired {
+		data["Inner"] = renderToMdoc(c, inner, body)
+	}
+	var md strings.Builder
+	if err := tmpl.Execute(&md, data); err != nil {
+		c.diags, c.uses = c.diags[:n], c.uses[:u]
+		c.report(offset, name, "markdown template: %v", err)
+		return "", false
+	}
+	return md.String(), true
+}
+
+// ref returns the link a ref or relref shortcode stands for, or raw, the
+// shortcode as written, if it cannot be resolved.
+func (c *converter) ref(toks []Tok, absolute bool, raw, name string, offset int) string {
+	target, found := "", false
+	for j := 0; j < len(toks); j++ {
+		if toks[j].Typ != "tScParam" {
+			continue
+		}
+		if j+1 < len(toks) && toks[j+1].Typ == "tScParamVal" {
+			if string(toks[j].Val) == "path" {
+				target, found = string(toks[j+1].Val), true
+			}
+			j++
+		} else if !found {
+			target, found = string(toks[j].Val), true
+		}
+	}
+	if !found {
+		c.report(offset, name, "no page to link to; kept as is")
+		return raw
+	}
+	url, err := c.site.Resolve(target, c.file, absolute)
 	if err != nil {
-		log.Fatalf("yaml marshal: %v", err)
+		c.report(offset, name, "cannot resolve %q: %v; kept as is", target, err)
+		return raw
 	}
+	return url
+}
+
+/* ------------------------------- Attributes ------------------------------- */
+
+// attrNameRe matches names Markdoc accepts for attributes.
+var attrNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+// param is a shortcode parameter with its typed value. Name is empty for a
+// positional parameter the schema does not name.
+type param struct {
+	Name  string
+	Value any
+}
+
+// params collects the parameters of one shortcode from its tokens, naming
+// positional ones through the schema and reporting those it cannot name.
+func (c *converter) params(toks []Tok, name string, offset int) []param {
+	var params []param
+	pos := 0
+	for j := 0; j < len(toks); j++ {
+		if toks[j].Typ != "tScParam" {
+			continue
+		}
+		if j+1 < len(toks) && toks[j+1].Typ == "tScParamVal" {
+			// Named: the value follows
+			params = append(params, param{Name: string(toks[j].Val), Value: toks[j+1].Value})
+			j++
+			continue
+		}
+		key := c.schema.positionalName(name, pos)
+		if key == "" {
+			c.report(offset, name, "positional parameter %d has no attribute name in the schema", pos)
+		}
+		params = append(params, param{Name: key, Value: toks[j].Value})
+		pos++
+	}
+	return params
+}
+
+// paramNames lists the parameters of one shortcode as Hugo names them:
+// named ones by name, positional ones by index.
+func paramNames(toks []Tok) []string {
+	var names []string
+	pos := 0
+	for j := 0; j < len(toks); j++ {
+		if toks[j].Typ != "tScParam" {
+			continue
+		}
+		if j+1 < len(toks) && toks[j+1].Typ == "tScParamVal" {
+			names = append(names, string(toks[j].Val))
+			j++
+			continue
+		}
+		names = append(names, strconv.Itoa(pos))
+		pos++
+	}
+	return names
+}
+
+// attributes renders params as Markdoc attributes, renamed through the
+// schema and followed by the defaults they do not set. Unnamed positional
+// parameters are kept as bare values, which Markdoc only accepts in first
+// place.
+func (c *converter) attributes(params []param, name string, offset int) []string {
+	var attrs []string
+	set := map[string]bool{}
+	for _, p := range params {
+		if p.Name == "" {
+			attrs = append(attrs, markdocValue(p.Value))
+			continue
+		}
+		key := c.schema.attribute(name, p.Name)
+		if !attrNameRe.MatchString(key) {
+			c.report(offset, name, "parameter %q is not a valid Markdoc attribute name", key)
+		}
+		set[key] = true
+		attrs = append(attrs, key+"="+markdocValue(p.Value))
+	}
+
+	defaults := c.schema.Shortcodes[name].Defaults
+	keys := make([]string, 0, len(defaults))
+	for k := range defaults {
+		if !set[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, k+"="+markdocValue(defaults[k]))
+	}
+	return attrs
+}
+
+func hasAttribute(attrs []string, name string) bool {
+	for _, a := range attrs {
+		if strings.HasPrefix(a, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// markdocValue renders a typed parameter value as a Markdoc literal.
+func markdocValue(v any) string {
+	switch v := v.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return markdocString(v)
+	}
+	return markdocString(fmt.Sprint(v))
+}
+
+// markdocString quotes s as a Markdoc string literal.
+func markdocString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func WriteMdocFile(outPath string, frontMatter []byte, body string) {
+	// Front matter as given, see TransformFrontMatter
 	var buf bytes.Buffer
-	buf.WriteString("---\n")
-	buf.Write(fm)
-	buf.WriteString("---\n")
+	buf.Write(frontMatter)
 	buf.WriteString(body)
 
 	if err := os.WriteFile(outPath, buf.Bytes(), 0o644); err != nil {
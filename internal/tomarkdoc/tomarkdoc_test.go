@@ -1,8 +1,13 @@
 package tomarkdoc
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"hugotranslationstudy/internal/frontmatter"
 )
 
 // Table-driven unit tests for focused cases
@@ -49,6 +54,26 @@ func TestConvertBodyToMdocTokens_Table(t *testing.T) {
 			in:   `{{< badge text="ONE" >}}{{< badge text="TWO" >}}`,
 			want: `{% badge text="ONE" /%}{% badge text="TWO" /%}`,
 		},
+		{
+			name: "code span untouched",
+			in:   "Use `{{< note \"x\" >}}` or {{< note \"y\" >}}",
+			want: "Use `{{< note \"x\" >}}` or {% note \"y\" /%}",
+		},
+		{
+			name: "fenced code untouched",
+			in:   "```\n{{< box >}}\n```\n{{< box >}}B{{< /box >}}",
+			want: "```\n{{< box >}}\n```\n{% box %}B{% /box %}",
+		},
+		{
+			name: "closing tag in code does not pair",
+			in:   "{{< box >}} `{{< /box >}}`",
+			want: "{% box /%} `{{< /box >}}`",
+		},
+		{
+			name: "indented code untouched",
+			in:   "Text\n\n    {{% badge %}}\n",
+			want: "Text\n\n    {{% badge %}}\n",
+		},
 		{
 			name: "no shortcode (pass-through)",
 			in:   "Just text **and** _markdown_.",
@@ -148,3 +173,560 @@ Done.
 		t.Fatalf("complex conversion mismatch:\n--- got ---\n%s\n--- want ---\n%s", got, want)
 	}
 }
+
+func TestConvert_Attributes(t *testing.T) {
+	t.Parallel()
+
+	schema := Schema{Shortcodes: map[string]ShortcodeSchema{
+		"note":   {Positional: []string{"text"}},
+		"figure": {Positional: []string{"src", "alt"}},
+	}}
+	tests := []struct {
+		name  string
+		in    string
+		want  string
+		diags []string
+	}{
+		{
+			name: "typed values",
+			in:   `{{< chart width=640 ratio=1.5 legend=true title="Sales" >}}`,
+			want: `{% chart width=640 ratio=1.5 legend=true title="Sales" /%}`,
+		},
+		{
+			name: "quoted values stay strings",
+			in:   `{{< feature enabled="true" size="10" >}}`,
+			want: `{% feature enabled="true" size="10" /%}`,
+		},
+		{
+			name: "raw string and escapes",
+			in:   "{{< code lang=`go` title=\"say \\\"hi\\\"\" >}}",
+			want: `{% code lang="go" title="say \"hi\"" /%}`,
+		},
+		{
+			name: "positional mapped by schema",
+			in:   `{{< note "Stay hydrated" >}}`,
+			want: `{% note text="Stay hydrated" /%}`,
+		},
+		{
+			name: "paired positional",
+			in:   `{{< figure "a.png" "A cat" >}}Caption{{< /figure >}}`,
+			want: `{% figure src="a.png" alt="A cat" %}Caption{% /figure %}`,
+		},
+		{
+			name:  "positional beyond the schema",
+			in:    `{{< note "Hi" 3 >}}`,
+			want:  `{% note text="Hi" 3 /%}`,
+			diags: []string{"note: positional parameter 1 has no attribute name in the schema"},
+		},
+		{
+			name:  "shortcode without schema",
+			in:    `Go {{< badge "NEW" >}}`,
+			want:  `Go {% badge "NEW" /%}`,
+			diags: []string{"badge: positional parameter 0 has no attribute name in the schema"},
+		},
+		{
+			name:  "invalid attribute name",
+			in:    `{{< badge data.x="1" >}}`,
+			want:  `{% badge data.x="1" /%}`,
+			diags: []string{`badge: parameter "data.x" is not a valid Markdoc attribute name`},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			res := Convert(tc.in, schema)
+			got, diags := res.Body, res.Diagnostics
+			if got != tc.want {
+				t.Fatalf("\nConvert(%q)\n  got : %q\n  want: %q", tc.in, got, tc.want)
+			}
+			var msgs []string
+			for _, d := range diags {
+				msgs = append(msgs, d.Shortcode+": "+d.Message)
+			}
+			if strings.Join(msgs, "\n") != strings.Join(tc.diags, "\n") {
+				t.Fatalf("diagnostics\n  got : %q\n  want: %q", msgs, tc.diags)
+			}
+		})
+	}
+}
+
+func TestConvert_Mapping(t *testing.T) {
+	t.Parallel()
+
+	schema := Schema{Shortcodes: map[string]ShortcodeSchema{
+		"note":       {Positional: []string{"text"}, Tag: "callout", Defaults: map[string]any{"type": "note"}},
+		"admonition": {Tag: "callout", Attributes: map[string]string{"type": "kind"}, Defaults: map[string]any{"kind": "info", "collapsible": false}},
+		"spacer":     {Drop: true},
+		"wrapper":    {Drop: true},
+		"figure":     {Markdown: `![{{.alt}}]({{.src}})`},
+		"details":    {Markdown: "**{{.summary}}**\n\n{{trim .Inner}}"},
+	}}
+	tests := []struct {
+		name  string
+		in    string
+		want  string
+		diags []string
+	}{
+		{
+			name: "renamed tag with default",
+			in:   `{{< note "Hi" >}}`,
+			want: `{% callout text="Hi" type="note" /%}`,
+		},
+		{
+			name: "renamed attribute, set attribute wins over default",
+			in:   `{{% admonition type="tip" %}}Body{{% /admonition %}}`,
+			want: `{% callout kind="tip" collapsible=false %}Body{% /callout %}`,
+		},
+		{
+			name: "dropped standalone",
+			in:   "A{{< spacer >}}B",
+			want: "AB",
+		},
+		{
+			name: "dropped paired keeps content",
+			in:   "{{< wrapper >}}X {{< note \"N\" >}}{{< /wrapper >}}",
+			want: `X {% callout text="N" type="note" /%}`,
+		},
+		{
+			name: "standalone to Markdown",
+			in:   `See {{< figure src="cat.png" alt="A cat" >}}.`,
+			want: `See ![A cat](cat.png).`,
+		},
+		{
+			name: "paired to Markdown",
+			in:   "{{< details summary=\"More\" >}}\nHidden {{< note \"N\" >}}\n{{< /details >}}",
+			want: "**More**\n\nHidden {% callout text=\"N\" type=\"note\" /%}",
+		},
+		{
+			name:  "missing template parameter keeps the tag",
+			in:    `{{< figure src="cat.png" >}}`,
+			want:  `{% figure src="cat.png" /%}`,
+			diags: []string{`figure: markdown template: template: markdown:1:4: executing "markdown" at <.alt>: map has no entry for key "alt"`},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			res := Convert(tc.in, schema)
+			got, diags := res.Body, res.Diagnostics
+			if got != tc.want {
+				t.Fatalf("\nConvert(%q)\n  got : %q\n  want: %q", tc.in, got, tc.want)
+			}
+			var msgs []string
+			for _, d := range diags {
+				msgs = append(msgs, d.Shortcode+": "+d.Message)
+			}
+			if strings.Join(msgs, "\n") != strings.Join(tc.diags, "\n") {
+				t.Fatalf("diagnostics\n  got : %q\n  want: %q", msgs, tc.diags)
+			}
+		})
+	}
+}
+
+func TestLoadSchema(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"markdoc.yaml": "shortcodes:\n  note:\n    tag: callout\n    defaults: {type: note}\n",
+		"markdoc.json": `{"shortcodes": {"note": {"tag": "callout", "defaults": {"type": "note"}}}}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		s, err := LoadSchema(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := Convert(`{{< note >}}`, s).Body; got != `{% callout type="note" /%}` {
+			t.Errorf("%s: Convert = %q", name, got)
+		}
+	}
+
+	path := filepath.Join(dir, "bad.yaml")
+	if err := os.WriteFile(path, []byte("shortcodes:\n  figure:\n    markdown: '{{.src'\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSchema(path); err == nil {
+		t.Error("LoadSchema accepted a broken template")
+	}
+
+	for _, rule := range []string{"{rename: a}", "{drop: a, set: b}", "{set: a, value: '{{'}"} {
+		if err := os.WriteFile(path, []byte("frontMatter:\n  - "+rule+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadSchema(path); err == nil {
+			t.Errorf("LoadSchema accepted the front matter rule %s", rule)
+		}
+	}
+}
+
+func TestConvert_Markup(t *testing.T) {
+	t.Parallel()
+
+	schema := Schema{MarkupAttribute: "markdown", Shortcodes: map[string]ShortcodeSchema{
+		"admonition": {Tag: "callout"},
+	}}
+	in := "{{% admonition %}}**Hi**{{% /admonition %}} {{< box >}}x{{< /box >}} {{% badge %}} {{% tag markdown=false %}}y{{% /tag %}}"
+	want := `{% callout markdown=true %}**Hi**{% /callout %} {% box %}x{% /box %} {% badge markdown=true /%} {% tag markdown=false %}y{% /tag %}`
+
+	res := Convert(in, schema)
+	if res.Body != want {
+		t.Fatalf("\nConvert(%q)\n  got : %q\n  want: %q", in, res.Body, want)
+	}
+	var review []string
+	for _, r := range res.Review {
+		review = append(review, fmt.Sprintf("%d %s", r.Offset, r.Shortcode))
+	}
+	if got, want := strings.Join(review, ", "), fmt.Sprintf("0 admonition, %d tag", strings.Index(in, "{{% tag")); got != want {
+		t.Fatalf("Review = %s, want %s", got, want)
+	}
+
+	// Without the attribute the notation is not kept, but still reviewed.
+	res = Convert(in, Schema{})
+	if strings.Contains(res.Body, "markdown=true") || len(res.Review) != 2 {
+		t.Fatalf("Convert without MarkupAttribute = %q, review %v", res.Body, res.Review)
+	}
+}
+
+func TestConvert_Forms(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		in    string
+		want  string
+		diags []string
+	}{
+		{
+			name: "self-closing",
+			in:   `{{< br />}} {{% hr /%}} {{< icon name="x" />}}`,
+			want: `{% br /%} {% hr /%} {% icon name="x" /%}`,
+		},
+		{
+			name: "self-closing does not pair with a later close",
+			in:   `{{< box />}} {{< box >}}x{{< /box >}}`,
+			want: `{% box /%} {% box %}x{% /box %}`,
+		},
+		{
+			name: "self-closing nested in the same name",
+			in:   `{{< box >}}{{< box />}}{{< /box >}}`,
+			want: `{% box %}{% box /%}{% /box %}`,
+		},
+		{
+			name: "escaped shortcode is text",
+			in:   `Write {{</* note */>}} to get a note.`,
+			want: `Write {{< note >}} to get a note.`,
+		},
+		{
+			name:  "inline shortcode",
+			in:    "A {{< time.inline >}}{{ now }}{{< /time.inline >}} B {{< time.inline />}}",
+			want:  "A {{< time.inline >}}{{ now }}{{< /time.inline >}} B {{< time.inline />}}",
+			diags: []string{"time.inline: inline shortcodes have no Markdoc equivalent; kept as is", "time.inline: inline shortcodes have no Markdoc equivalent; kept as is"},
+		},
+		{
+			name:  "closing whose opening is in code",
+			in:    "`{{< box >}}` x {{< /box >}}",
+			want:  "`{{< box >}}` x ",
+			diags: []string{"box: closing shortcode without an opening one; left out"},
+		},
+		{
+			name:  "mismatched closing",
+			in:    `{{< a >}}x{{< /b >}}`,
+			want:  `{% a /%}x{{< /b >}}`,
+			diags: []string{`: Hugo cannot parse the page from here (closing tag for shortcode 'b' does not match start tag); the rest is kept as is`},
+		},
+		{
+			name:  "lexer error",
+			in:    "{{< badge >}} A {{< note \"open >}} B {{< badge >}}",
+			want:  "{% badge /%} A {{< note \"open >}} B {{< badge >}}",
+			diags: []string{`: Hugo cannot parse the page from here (unterminated quoted string in shortcode parameter-argument: 'open >}} B {{< badge >}}'); the rest is kept as is`},
+		},
+		{
+			name:  "unterminated",
+			in:    "A {{< badge",
+			want:  "A {{< badge",
+			diags: []string{": Hugo cannot parse the page from here (unclosed shortcode action); the rest is kept as is"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			res := Convert(tc.in, Schema{})
+			if res.Body != tc.want {
+				t.Fatalf("\nConvert(%q)\n  got : %q\n  want: %q", tc.in, res.Body, tc.want)
+			}
+			var msgs []string
+			for _, d := range res.Diagnostics {
+				msgs = append(msgs, d.Shortcode+": "+d.Message)
+			}
+			if strings.Join(msgs, "\n") != strings.Join(tc.diags, "\n") {
+				t.Fatalf("diagnostics\n  got : %q\n  want: %q", msgs, tc.diags)
+			}
+		})
+	}
+}
+
+func TestTransformFrontMatter(t *testing.T) {
+	t.Parallel()
+
+	rules := []FrontMatterRule{
+		{Rename: "menu.main.weight", To: "nav.order"},
+		{Rename: "linkTitle", To: "navTitle"},
+		{Set: "nav.section", Value: "{{ lower .type }}"},
+		{Set: "nav.hidden", Value: "{{ .draft }}"},
+		{Set: "summary", Value: "{{ .description }}"},
+		{Drop: "aliases"},
+		{Drop: "weight"},
+		{Drop: "menu"},
+		{Drop: "outputs"},
+	}
+	tests := []struct {
+		name, in, want string
+		notes          []string
+	}{
+		{
+			name: "yaml keeps order and comments",
+			in:   "---\n# Page\ntitle: \"Hi\"\nlinkTitle: Hi # short\ntype: Docs\ndraft: true\nweight: 3\naliases: [/old]\nmenu:\n  main:\n    weight: 20\n---\n",
+			want: "---\n# Page\ntitle: \"Hi\"\nnavTitle: Hi # short\ntype: Docs\ndraft: true\nnav:\n  order: 20\n  section: docs\n  hidden: true\n---\n",
+			notes: []string{
+				"dropped empty menu.main",
+				"dropped empty menu",
+				`did not set summary: template: value:1:3: executing "value" at <.description>: map has no entry for key "description"`,
+				"dropped aliases",
+				"dropped weight",
+			},
+		},
+		{
+			name: "menu keeps what was not moved",
+			in:   "---\ntype: a\ndraft: false\nmenu:\n  main:\n    weight: 1\n    name: Home\n---\n",
+			want: "---\ntype: a\ndraft: false\nnav:\n  order: 1\n  section: a\n  hidden: false\n---\n",
+			notes: []string{
+				`did not set summary: template: value:1:3: executing "value" at <.description>: map has no entry for key "description"`,
+				"dropped menu",
+			},
+		},
+		{
+			name:  "toml",
+			in:    "+++\ntitle = 'T'\ntype = 'X'\ndraft = false\ndescription = 'D'\noutputs = ['html']\n+++\n",
+			want:  "---\ndescription: D\ndraft: false\ntitle: T\ntype: X\nnav:\n  section: x\n  hidden: false\nsummary: D\n---\n",
+			notes: []string{"dropped outputs"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			fm, err := frontmatter.Parse([]byte(tc.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, notes, err := TransformFrontMatter(fm, rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("TransformFrontMatter\n  got : %q\n  want: %q", got, tc.want)
+			}
+			if strings.Join(notes, "\n") != strings.Join(tc.notes, "\n") {
+				t.Errorf("notes\n  got : %q\n  want: %q", notes, tc.notes)
+			}
+		})
+	}
+
+	// Renames onto a key that is there replace it, with a note
+	for _, tc := range []struct {
+		rule      FrontMatterRule
+		in, want  string
+		wantNotes []string
+	}{
+		{
+			rule:      FrontMatterRule{Rename: "linkTitle", To: "title"},
+			in:        "---\ntitle: A\nlinkTitle: B\n---\n",
+			want:      "---\ntitle: B\n---\n",
+			wantNotes: []string{"replaced title"},
+		},
+		{
+			rule:      FrontMatterRule{Rename: "params.order", To: "nav.order"},
+			in:        "---\nnav:\n  order: 1\nparams:\n  order: 2\n---\n",
+			want:      "---\nnav:\n  order: 2\n---\n",
+			wantNotes: []string{"dropped empty params", "replaced nav.order"},
+		},
+		{
+			rule:      FrontMatterRule{Rename: "weight", To: "nav.order"},
+			in:        "---\nnav: top\nweight: 2\n---\n",
+			want:      "---\nnav:\n  order: 2\n---\n",
+			wantNotes: []string{"replaced nav"},
+		},
+	} {
+		fm, err := frontmatter.Parse([]byte(tc.in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, notes, err := TransformFrontMatter(fm, []FrontMatterRule{tc.rule})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tc.want || strings.Join(notes, "\n") != strings.Join(tc.wantNotes, "\n") {
+			t.Errorf("%s -> %s: got %q, %q; want %q, %q", tc.rule.Rename, tc.rule.To, got, notes, tc.want, tc.wantNotes)
+		}
+	}
+
+	// No rules: untouched
+	in := "+++\ntitle = 'T' # c\n+++\n"
+	fm, _ := frontmatter.Parse([]byte(in))
+	if got, _, _ := TransformFrontMatter(fm, nil); string(got) != in {
+		t.Errorf("without rules = %q", got)
+	}
+}
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	schema := Schema{Shortcodes: map[string]ShortcodeSchema{
+		"figure": {Markdown: "![{{.alt}}]({{.src}})"},
+		"note":   {Positional: []string{"text"}},
+	}}
+	pages := []string{
+		"{{< note \"Hi\" >}}\n{{% box %}}x{{< note \"a\" >}}{{% /box %}}\n",
+		"{{< figure src=\"a.png\" >}}\n{{< note text=\"b\" >}}{{< /note >}}\n",
+	}
+	var r Report
+	for i, body := range pages {
+		line := func(offset int) int { return 1 + strings.Count(body[:offset], "\n") }
+		r.Add(fmt.Sprintf("p%d.md", i), Convert(body, schema), line, nil)
+	}
+
+	stats := func(s []ShortcodeStats) string {
+		var out []string
+		for _, s := range s {
+			out = append(out, fmt.Sprintf("%s %d %d/%d %d %v %d", s.Name, s.Count, s.Paired, s.Standalone, s.Markup, s.Params, s.Warnings))
+		}
+		return strings.Join(out, "\n")
+	}
+	want := []string{
+		"box 1 1/0 1 [] 0\nnote 2 0/2 0 [0] 0",
+		"figure 1 0/1 0 [src] 1\nnote 1 1/0 0 [text] 0",
+	}
+	for i, f := range r.Files {
+		if got := stats(f.Shortcodes); got != want[i] {
+			t.Errorf("%s\n  got : %q\n  want: %q", f.Source, got, want[i])
+		}
+	}
+	if got, want := stats(r.Totals), "box 1 1/0 1 [] 0\nfigure 1 0/1 0 [src] 1\nnote 3 1/2 0 [0 text] 0"; got != want {
+		t.Errorf("totals\n  got : %q\n  want: %q", got, want)
+	}
+	if w := r.Files[1].Warnings; len(w) != 1 || w[0].Line != 1 || w[0].Shortcode != "figure" {
+		t.Errorf("warnings = %+v", w)
+	}
+	if md := r.Markdown(); !strings.Contains(md, "2 file(s), 5 shortcode(s), 3 distinct.") ||
+		!strings.Contains(md, "| `note` | 3 | 1 | 2 | 0 | `0`, `text` | 0 |") {
+		t.Errorf("Markdown() =\n%s", md)
+	}
+}
+
+func TestSite_Resolve(t *testing.T) {
+	t.Parallel()
+
+	site := NewSite("https://example.org/", "en", nil)
+	site.AddPage("_index.md", nil)
+	site.AddPage("blog/_index.md", map[string]any{"title": "Blog"})
+	site.AddPage("blog/First Post.md", nil)
+	site.AddPage("blog/second.md", map[string]any{"Slug": "two", "aliases": []any{"/old/second/"}})
+	site.AddPage("blog/bundle/index.md", nil)
+	site.AddPage("docs/install.md", map[string]any{"url": "setup/"})
+	site.AddPage("docs/usage/index.md", map[string]any{"slug": "use"})
+	site.AddPage("docs/intro.md", nil)
+	site.AddPage("guides/intro.md", nil)
+
+	tests := []struct {
+		target, from string
+		absolute     bool
+		want, err    string
+	}{
+		{target: "/blog/second.md", from: "docs/intro.md", want: "/blog/two/"},
+		{target: "second.md", from: "blog/first post.md", want: "/blog/two/"},
+		{target: "../docs/install.md#linux", from: "blog/second.md", want: "/setup/#linux"},
+		{target: "install", from: "blog/second.md", want: "/setup/"},
+		{target: "First Post.md", from: "docs/intro.md", want: "/blog/first-post/"},
+		{target: "blog/bundle", from: "docs/intro.md", want: "/blog/bundle/"},
+		{target: "/docs/usage/index.md", from: "docs/intro.md", want: "/docs/use/"},
+		{target: "/old/second", from: "docs/intro.md", want: "/blog/two/"},
+		{target: "/blog", from: "docs/intro.md", want: "/blog/"},
+		{target: "docs", from: "blog/second.md", want: "/docs/"},
+		{target: "/", from: "blog/second.md", absolute: true, want: "https://example.org/"},
+		{target: "#usage", from: "blog/second.md", absolute: true, want: "https://example.org/blog/two/#usage"},
+		{target: "intro.md", from: "docs/install.md", want: "/docs/intro/"},
+		{target: "intro.md", from: "blog/second.md", err: "ambiguous, it matches docs/intro, guides/intro"},
+		{target: "missing.md", from: "blog/second.md", err: "no such page"},
+	}
+	for _, tc := range tests {
+		got, err := site.Resolve(tc.target, tc.from, tc.absolute)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("Resolve(%q, %q) error = %v, want %q", tc.target, tc.from, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("Resolve(%q, %q) = %q, %v; want %q", tc.target, tc.from, got, err, tc.want)
+		}
+	}
+}
+
+func TestSite_Languages(t *testing.T) {
+	t.Parallel()
+
+	site := NewSite("", "en", []string{"en", "fr"})
+	site.AddPage("blog/post.en.md", nil)
+	site.AddPage("blog/post.fr.md", map[string]any{"slug": "article"})
+	site.AddPage("blog/only.md", nil)
+	site.AddPage("blog/_index.fr.md", nil)
+	site.AddPage("docs/v1.2.md", nil)
+
+	for _, tc := range []struct{ target, from, want string }{
+		{"blog/post.md", "docs/v1.2.md", "/blog/post/"},
+		{"post", "docs/v1.2.md", "/blog/post/"},
+		{"post.md", "blog/only.md", "/blog/post/"},
+		{"blog/post.en.md", "docs/v1.2.md", "/blog/post/"},
+		{"post", "blog/post.fr.md", "/fr/blog/article/"},
+		{"/blog/post.md", "blog/post.fr.md", "/fr/blog/article/"},
+		{"/blog", "blog/post.fr.md", "/fr/blog/"},
+		{"only.md", "blog/post.fr.md", "/blog/only/"}, // no French page, so the English one
+		{"v1.2", "blog/only.md", "/docs/v1.2/"},       // not a language
+	} {
+		got, err := site.Resolve(tc.target, tc.from, false)
+		if err != nil || got != tc.want {
+			t.Errorf("Resolve(%q, %q) = %q, %v; want %q", tc.target, tc.from, got, err, tc.want)
+		}
+	}
+}
+
+func TestConvertPage_Refs(t *testing.T) {
+	t.Parallel()
+
+	site := NewSite("https://example.org", "en", nil)
+	site.AddPage("blog/post.md", nil)
+	site.AddPage("blog/other.md", nil)
+
+	body := "See [post]({{< relref \"post.md\" >}}), [abs]({{< ref path=\"/blog/other.md#top\" >}}) " +
+		"and [gone]({{< relref \"gone.md\" >}}).\n"
+	got := ConvertPage(body, Schema{}, site, "blog/other.md")
+	want := "See [post](/blog/post/), [abs](https://example.org/blog/other/#top) " +
+		"and [gone]({{< relref \"gone.md\" >}}).\n"
+	if got.Body != want {
+		t.Errorf("ConvertPage\n  got : %q\n  want: %q", got.Body, want)
+	}
+	if len(got.Diagnostics) != 1 || got.Diagnostics[0].Message != `cannot resolve "gone.md": no such page; kept as is` {
+		t.Errorf("diagnostics = %+v", got.Diagnostics)
+	}
+
+	// Without a site, ref is a shortcode like any other.
+	if got := Convert(`{{< ref "post.md" >}}`, Schema{}).Body; got != `{% ref "post.md" /%}` {
+		t.Errorf("Convert without a site = %q", got)
+	}
+}
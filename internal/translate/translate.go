@@ -0,0 +1,75 @@
+package translate
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"hugotranslationstudy/internal/piglatin"
+)
+
+// Translator turns a batch of source segments into target segments.
+// Implementations must return exactly one translation per segment,
+// in the same order.
+type Translator interface {
+	Translate(segments []string, sourceLocale, targetLocale string) ([]string, error)
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Translator{}
+)
+
+func init() {
+	Register("piglatin", PigLatin{})
+}
+
+// Register makes a translator available under name. It panics if the
+// name is already taken, since that is always a programming error.
+func Register(name string, t Translator) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("translate: Register called twice for " + name)
+	}
+	registry[name] = t
+}
+
+// Lookup returns the translator registered under name.
+func Lookup(name string) (Translator, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	t, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown translator %q (available: %v)", name, namesLocked())
+	}
+	return t, nil
+}
+
+// Names lists the registered translators in alphabetical order.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return namesLocked()
+}
+
+func namesLocked() []string {
+	names := make([]string, 0, len(registry))
+	for n := range registry {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PigLatin is the toy translator used throughout this study.
+// It ignores the locales.
+type PigLatin struct{}
+
+func (PigLatin) Translate(segments []string, _, _ string) ([]string, error) {
+	out := make([]string, len(segments))
+	for i, s := range segments {
+		out[i] = piglatin.ToPigLatin(s)
+	}
+	return out, nil
+}
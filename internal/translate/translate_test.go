@@ -0,0 +1,69 @@
+package translate
+
+import (
+	"strings"
+	"testing"
+)
+
+type upper struct{}
+
+func (upper) Translate(segments []string, _, _ string) ([]string, error) {
+	out := make([]string, len(segments))
+	for i, s := range segments {
+		out[i] = strings.ToUpper(s)
+	}
+	return out, nil
+}
+
+func TestPigLatin_Batch(t *testing.T) {
+	t.Parallel()
+
+	got, err := PigLatin{}.Translate([]string{"Hello ", "world", ""}, "en", "x-piglatin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Ellohay ", "orldway", ""}
+	if len(got) != len(want) {
+		t.Fatalf("got %d results, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("segment %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	Register("test-upper", upper{})
+
+	tr, err := Lookup("test-upper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tr.Translate([]string{"abc"}, "en", "fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != "ABC" {
+		t.Errorf("got %q, want %q", got[0], "ABC")
+	}
+
+	if _, err := Lookup("piglatin"); err != nil {
+		t.Errorf("piglatin should be registered by default: %v", err)
+	}
+	if _, err := Lookup("no-such-translator"); err == nil {
+		t.Error("expected an error for an unknown translator")
+	}
+
+	found := false
+	for _, n := range Names() {
+		if n == "test-upper" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Names() = %v, missing test-upper", Names())
+	}
+}
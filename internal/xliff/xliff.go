@@ -0,0 +1,370 @@
+package xliff
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"hugotranslationstudy/internal/subtokenize"
+)
+
+// Namespace is the XLIFF 2.0 core namespace.
+const Namespace = "urn:oasis:names:tc:xliff:document:2.0"
+
+// File groups the units of one content file. Original is the source path.
+type File struct {
+	Original string
+	Units    []Unit
+}
+
+// Unit is one translatable unit, such as the subtokens of a tText token or
+// a single shortcode parameter value. Markup subtokens become inline codes:
+// <pc> when subtokenize.Pairs finds a partner, <ph> otherwise. Their bytes go
+// into <originalData>, so translators only ever see and move the codes.
+type Unit struct {
+	ID        string
+	Name      string // optional, e.g. the front matter path
+	Subtokens []subtokenize.Subtoken
+}
+
+// Target is the translation of one unit with the markup restored.
+type Target struct {
+	File string
+	Unit string
+	Text string
+	Err  error // with ReadDocument, what is wrong with the inline codes of the target
+}
+
+// Write renders files as an XLIFF 2.0 document with empty targets.
+func Write(w io.Writer, srcLang, trgLang string, files []File) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(bw, "<xliff xmlns=\"%s\" version=\"2.0\" srcLang=\"%s\" trgLang=\"%s\">\n",
+		Namespace, escape(srcLang, true), escape(trgLang, true))
+	for i, f := range files {
+		fmt.Fprintf(bw, "  <file id=\"f%d\" original=\"%s\">\n", i+1, escape(f.Original, true))
+		for _, u := range f.Units {
+			writeUnit(bw, u)
+		}
+		fmt.Fprintf(bw, "  </file>\n")
+	}
+	fmt.Fprintf(bw, "</xliff>\n")
+	return bw.Flush()
+}
+
+func writeUnit(w *bufio.Writer, u Unit) {
+	fmt.Fprintf(w, "    <unit id=\"%s\"", escape(u.ID, true))
+	if u.Name != "" {
+		fmt.Fprintf(w, " name=\"%s\"", escape(u.Name, true))
+	}
+	fmt.Fprintf(w, ">\n")
+
+	pairs := subtokenize.Pairs(u.Subtokens)
+	var data []string
+	var src strings.Builder
+	for i, s := range u.Subtokens {
+		if s.Type != "markup" {
+			src.WriteString(escape(s.Val, false))
+			continue
+		}
+		data = append(data, s.Val)
+		ref := "d" + strconv.Itoa(len(data))
+		switch p := pairs[i]; {
+		case p > i:
+			// The end tag's data id is assigned when we get there, but
+			// markup ids are sequential so it is known already.
+			end := len(data) + countMarkup(u.Subtokens[i+1:p]) + 1
+			fmt.Fprintf(&src, `<pc id="%d" dataRefStart="%s" dataRefEnd="d%d" canCopy="no" canDelete="no">`,
+				len(data), ref, end)
+		case p >= 0:
+			src.WriteString("</pc>")
+		default:
+			fmt.Fprintf(&src, `<ph id="%d" dataRef="%s" canCopy="no" canDelete="no"/>`, len(data), ref)
+		}
+	}
+
+	if len(data) > 0 {
+		fmt.Fprintf(w, "      <originalData>\n")
+		for i, d := range data {
+			fmt.Fprintf(w, "        <data id=\"d%d\" xml:space=\"preserve\">%s</data>\n", i+1, escape(d, false))
+		}
+		fmt.Fprintf(w, "      </originalData>\n")
+	}
+	fmt.Fprintf(w, "      <segment>\n")
+	fmt.Fprintf(w, "        <source xml:space=\"preserve\">%s</source>\n", src.String())
+	fmt.Fprintf(w, "      </segment>\n")
+	fmt.Fprintf(w, "    </unit>\n")
+}
+
+func countMarkup(subs []subtokenize.Subtoken) int {
+	n := 0
+	for _, s := range subs {
+		if s.Type == "markup" {
+			n++
+		}
+	}
+	return n
+}
+
+// escape escapes text for XML content or attribute values. Characters that
+// XML 1.0 cannot carry at all become XLIFF <cp> elements in content.
+func escape(s string, attr bool) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '&':
+			b.WriteString("&amp;")
+		case r == '<':
+			b.WriteString("&lt;")
+		case r == '>':
+			b.WriteString("&gt;")
+		case r == '"' && attr:
+			b.WriteString("&quot;")
+		case r == '\r', (r == '\n' || r == '\t') && attr:
+			fmt.Fprintf(&b, "&#x%X;", r)
+		case r < 0x20 && r != '\n' && r != '\t', r == 0xFFFE, r == 0xFFFF:
+			if !attr {
+				fmt.Fprintf(&b, `<cp hex="%04X"/>`, r)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+/* -------------------------------- Reading -------------------------------- */
+
+// Document is a translated XLIFF 2.0 document.
+type Document struct {
+	SrcLang string
+	TrgLang string
+	Targets []Target
+}
+
+// Read parses a translated XLIFF 2.0 document. Units whose segments have no
+// <target> are left out, so callers fall back to the source. It fails if a
+// target drops, duplicates or invents an inline code. Quotes in attribute
+// values and titles are escaped for the markup around them.
+func Read(r io.Reader) ([]Target, error) {
+	doc, err := ReadDocument(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range doc.Targets {
+		if t.Err != nil {
+			return nil, fmt.Errorf("%s: unit %s: %w", t.File, t.Unit, t.Err)
+		}
+	}
+	return doc.Targets, nil
+}
+
+// ReadDocument is Read, also returning the languages of the document. It
+// keeps targets whose inline codes do not match their source, with the codes
+// they have, and sets their Err instead of failing.
+func ReadDocument(r io.Reader) (*Document, error) {
+	dec := xml.NewDecoder(r)
+	doc := &Document{}
+
+	var file string
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return doc, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "xliff":
+			doc.SrcLang, doc.TrgLang = attr(se, "srcLang"), attr(se, "trgLang")
+		case "file":
+			file = attr(se, "original")
+		case "unit":
+			t, ok, err := readUnit(dec, se)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			if ok {
+				t.File = file
+				doc.Targets = append(doc.Targets, t)
+			}
+		}
+	}
+}
+
+// inline is a piece of <source> or <target> content: text, or a reference
+// to original data.
+type inline struct {
+	text string
+	ref  string
+}
+
+func readUnit(dec *xml.Decoder, start xml.StartElement) (Target, bool, error) {
+	id := attr(start, "id")
+	data := map[string]string{}
+	var source, target []inline
+	hasTarget := false
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return Target{}, false, err
+		}
+		switch t := tok.(type) {
+		case xml.EndElement:
+			if t.Name.Local != "unit" {
+				continue
+			}
+			if !hasTarget {
+				return Target{}, false, nil
+			}
+			codesErr := sameCodes(source, target, data)
+			var b strings.Builder
+			before := "" // data of the last code
+			for _, in := range target {
+				if in.ref == "" {
+					// Text after a code ending in a quote is an attribute
+					// value or a title
+					b.WriteString(subtokenize.EscapeAttribute(in.text, before))
+					continue
+				}
+				d, ok := data[in.ref]
+				if !ok && codesErr == nil {
+					return Target{}, false, fmt.Errorf("unit %s: unknown data id %q", id, in.ref)
+				}
+				b.WriteString(d)
+				before = d
+			}
+			return Target{Unit: id, Text: b.String(), Err: codesErr}, true, nil
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "data":
+				content, err := readInline(dec, "data")
+				if err != nil {
+					return Target{}, false, err
+				}
+				data[attr(t, "id")] = joinText(content)
+			case "source":
+				content, err := readInline(dec, "source")
+				if err != nil {
+					return Target{}, false, err
+				}
+				source = append(source, content...)
+			case "target":
+				content, err := readInline(dec, "target")
+				if err != nil {
+					return Target{}, false, err
+				}
+				target = append(target, content...)
+				hasTarget = true
+			}
+		}
+	}
+}
+
+// readInline reads the content of the element just opened, up to its end.
+func readInline(dec *xml.Decoder, name string) ([]inline, error) {
+	var out []inline
+	var pcEnds []string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			out = append(out, inline{text: string(t)})
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "ph":
+				out = append(out, inline{ref: attr(t, "dataRef")})
+			case "pc":
+				out = append(out, inline{ref: attr(t, "dataRefStart")})
+				pcEnds = append(pcEnds, attr(t, "dataRefEnd"))
+			case "cp":
+				n, err := strconv.ParseUint(attr(t, "hex"), 16, 32)
+				if err != nil {
+					return nil, fmt.Errorf("bad <cp hex=%q>", attr(t, "hex"))
+				}
+				out = append(out, inline{text: string(rune(n))})
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case name:
+				return out, nil
+			case "pc":
+				if len(pcEnds) == 0 {
+					return nil, fmt.Errorf("unbalanced </pc>")
+				}
+				out = append(out, inline{ref: pcEnds[len(pcEnds)-1]})
+				pcEnds = pcEnds[:len(pcEnds)-1]
+			}
+		}
+	}
+}
+
+// sameCodes checks that target uses every code of source exactly once. The
+// error names each code that is missing or extra, with its markup if known.
+func sameCodes(source, target []inline, data map[string]string) error {
+	count := map[string]int{}
+	var refs []string // in order of appearance
+	for _, in := range source {
+		if in.ref != "" {
+			if _, ok := count[in.ref]; !ok {
+				refs = append(refs, in.ref)
+			}
+			count[in.ref]++
+		}
+	}
+	for _, in := range target {
+		if in.ref != "" {
+			if _, ok := count[in.ref]; !ok {
+				refs = append(refs, in.ref)
+			}
+			count[in.ref]--
+		}
+	}
+	var problems []string
+	for _, ref := range refs {
+		code := ref
+		if d, ok := data[ref]; ok {
+			code += fmt.Sprintf(" (%q)", d)
+		}
+		switch n := count[ref]; {
+		case n > 0:
+			problems = append(problems, "target is missing inline code "+code)
+		case n < 0:
+			problems = append(problems, "target has an extra inline code "+code)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+func joinText(in []inline) string {
+	var b strings.Builder
+	for _, x := range in {
+		b.WriteString(x.text)
+	}
+	return b.String()
+}
+
+func attr(se xml.StartElement, name string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
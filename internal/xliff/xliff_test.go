@@ -0,0 +1,168 @@
+package xliff
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"hugotranslationstudy/internal/subtokenize"
+)
+
+// fillTargets copies every <source> into a <target>, passing it through fn.
+func fillTargets(doc string, fn func(string) string) string {
+	re := regexp.MustCompile(`(?s)<source xml:space="preserve">(.*?)</source>`)
+	return re.ReplaceAllStringFunc(doc, func(m string) string {
+		src := re.FindStringSubmatch(m)[1]
+		return m + `<target xml:space="preserve">` + fn(src) + `</target>`
+	})
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	subs, err := subtokenize.Subtokenize([]byte("Some **bold** text, a [link](https://x.y?a=1&b=2) and <em>x < y</em>.\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []File{{
+		Original: "content/a.md",
+		Units: []Unit{
+			{ID: "t0", Subtokens: subs},
+			{ID: "fm0", Name: "title", Subtokens: []subtokenize.Subtoken{{Type: "text", Val: "Tab\there \"q\"\x01"}}},
+		},
+	}}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "en", "de", files); err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.String()
+	for _, want := range []string{
+		`<pc id="1" dataRefStart="d1" dataRefEnd="d2"`,
+		`<cp hex="0001"/>`,
+		`x &lt; y`,
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("export lacks %s:\n%s", want, doc)
+		}
+	}
+
+	// Untranslated: nothing comes back but the languages
+	d, err := ReadDocument(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.SrcLang != "en" || d.TrgLang != "de" || len(d.Targets) != 0 {
+		t.Fatalf("ReadDocument without targets = %+v", d)
+	}
+
+	// Identity translation restores the original text
+	got, err := Read(strings.NewReader(fillTargets(doc, func(s string) string { return s })))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Target{
+		{File: "content/a.md", Unit: "t0", Text: "Some **bold** text, a [link](https://x.y?a=1&b=2) and <em>x < y</em>.\n"},
+		{File: "content/a.md", Unit: "fm0", Text: "Tab\there \"q\"\x01"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d targets, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("target %d\n  got : %q\n  want: %q", i, got[i], want[i])
+		}
+	}
+
+	// Translators may move codes around
+	got, err = Read(strings.NewReader(fillTargets(doc, func(s string) string {
+		return strings.Replace(s, "Some ", "Etwas ", 1)
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got[0].Text, "Etwas **bold**") {
+		t.Errorf("translated target = %q", got[0].Text)
+	}
+}
+
+func TestRead_AttributeQuotes(t *testing.T) {
+	t.Parallel()
+
+	src := "See <img src=\"a.png\" alt=\"A diagram\"> <span title='Tip'>here</span> and [docs](/d \"Docs\").\n"
+	subs, err := subtokenize.Subtokenize([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, "en", "de", []File{{Original: "a.md", Units: []Unit{{ID: "t0", Subtokens: subs}}}}); err != nil {
+		t.Fatal(err)
+	}
+	doc := fillTargets(buf.String(), func(s string) string {
+		s = strings.Replace(s, "A diagram", `Ein "Diagramm"`, 1)
+		s = strings.Replace(s, "Tip", "Kevin's Tipp", 1)
+		s = strings.Replace(s, "here", `"hier"`, 1)
+		return strings.Replace(s, ">Docs<", `>Die "Doku"<`, 1)
+	})
+	got, err := Read(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "See <img src=\"a.png\" alt=\"Ein &quot;Diagramm&quot;\"> <span title='Kevin&#39;s Tipp'>\"hier\"</span> " +
+		"and [docs](/d \"Die &quot;Doku&quot;\").\n"
+	if len(got) != 1 || got[0].Text != want {
+		t.Errorf("Read = %q, want %q", got, want)
+	}
+}
+
+func TestRead_DamagedCodes(t *testing.T) {
+	t.Parallel()
+
+	const unit = `<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0">
+<file id="f1" original="a.md"><unit id="t0">
+<originalData><data id="d1">**</data><data id="d2">**</data></originalData>
+<segment><source><pc id="1" dataRefStart="d1" dataRefEnd="d2">bold</pc></source>
+<target>%s</target></segment></unit></file></xliff>`
+
+	tests := []struct {
+		name, target, text, err string
+	}{
+		{
+			name:   "dropped",
+			target: "fett",
+			text:   "fett",
+			err:    `target is missing inline code d1 ("**"); target is missing inline code d2 ("**")`,
+		},
+		{
+			name:   "duplicated",
+			target: `<pc id="1" dataRefStart="d1" dataRefEnd="d2">a</pc><pc id="1" dataRefStart="d1" dataRefEnd="d2">b</pc>`,
+			text:   "**a****b**",
+			err:    `target has an extra inline code d1 ("**"); target has an extra inline code d2 ("**")`,
+		},
+		{
+			name:   "invented",
+			target: `<pc id="1" dataRefStart="d1" dataRefEnd="d2">a</pc><ph id="9" dataRef="d9"/>`,
+			text:   "**a**",
+			err:    "target has an extra inline code d9",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			doc := strings.Replace(unit, "%s", tc.target, 1)
+			if _, err := Read(strings.NewReader(doc)); err == nil {
+				t.Fatalf("Read accepted target %q", tc.target)
+			}
+			// ReadDocument keeps the unit and says what is wrong
+			d, err := ReadDocument(strings.NewReader(doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(d.Targets) != 1 || d.Targets[0].Text != tc.text || d.Targets[0].Err == nil || d.Targets[0].Err.Error() != tc.err {
+				t.Fatalf("ReadDocument = %+v, want text %q and error %q", d.Targets, tc.text, tc.err)
+			}
+		})
+	}
+}
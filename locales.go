@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"hugotranslationstudy/internal/hugoconfig"
+)
+
+// Layouts for translated pages in the Hugo site tree, after Hugo's two ways
+// of organizing translations.
+const (
+	layoutFilename  = "filename"  // content/blog/post.fr.md
+	layoutDirectory = "directory" // content/fr/blog/post.md
+)
+
+// siteRoot is where Hugo-ready translations go, under outRoot.
+const siteRoot = "hugo"
+
+// localeSetup is what the target locales were resolved to.
+type localeSetup struct {
+	Locales   []string
+	Site      hugoconfig.Site
+	WriteSite bool // write the Hugo site tree under out/hugo
+}
+
+// resolveLocales picks the target locales: the -locales list if given,
+// otherwise the languages of the Hugo site config, otherwise -target-locale
+// alone. The site tree is written whenever -locales or a site config with
+// languages is in play. sourceSet tells whether -source-locale was given;
+// if not, the site's default language becomes the source locale.
+func resolveLocales(localesFlag, hugoConfigPath, targetLocale string, sourceLocale *string, sourceSet bool) (localeSetup, error) {
+	setup := localeSetup{Site: hugoconfig.Site{DefaultLanguage: *sourceLocale, ContentDir: contentRoot}}
+
+	path := hugoConfigPath
+	if path == "" {
+		path, _ = hugoconfig.Find(".")
+	}
+	if path != "" {
+		site, err := hugoconfig.Load(path)
+		if err != nil {
+			return setup, err
+		}
+		setup.Site = site
+		if !sourceSet && len(site.Languages) > 0 {
+			*sourceLocale = site.DefaultLanguage
+		}
+	}
+
+	switch {
+	case localesFlag != "":
+		for _, l := range strings.Split(localesFlag, ",") {
+			if l = strings.TrimSpace(l); l != "" {
+				setup.Locales = append(setup.Locales, l)
+			}
+		}
+		if len(setup.Locales) == 0 {
+			return setup, fmt.Errorf("-locales %q lists no locales", localesFlag)
+		}
+		setup.WriteSite = true
+	case len(setup.Site.Languages) > 0:
+		locales, err := setup.Site.TargetLocales()
+		if err != nil {
+			return setup, fmt.Errorf("%s: %w", path, err)
+		}
+		setup.Locales = locales
+		setup.WriteSite = true
+	default:
+		setup.Locales = []string{targetLocale}
+	}
+	return setup, nil
+}
+
+// localized names a per-locale output file: name itself when there is only
+// one locale, name with the locale before the extension otherwise
+// (translated.md -> translated.fr.md).
+func localized(name, locale string, multi bool) string {
+	if !multi {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + locale + ext
+}
+
+// sitePath returns where the translation of a content file goes in the Hugo
+// site tree. A language the source already carries, as a ".en" filename
+// suffix or (directory layout) an "en/" top directory, is replaced.
+func sitePath(path, locale, sourceLocale, layout string, site hugoconfig.Site) (string, error) {
+	rel, err := filepath.Rel(contentRoot, path)
+	if err != nil {
+		return "", fmt.Errorf("rel path: %w", err)
+	}
+	dir, file := filepath.Split(rel)
+	base := strings.TrimSuffix(file, filepath.Ext(file))
+	base = strings.TrimSuffix(base, "."+sourceLocale)
+
+	root := filepath.Join(outRoot, siteRoot)
+	switch layout {
+	case layoutDirectory:
+		dir = strings.TrimPrefix(filepath.ToSlash(dir), sourceLocale+"/")
+		langDir := site.ContentDirFor(locale)
+		if langDir == "" {
+			langDir = filepath.Join(site.ContentDir, locale)
+		}
+		return filepath.Join(root, langDir, filepath.FromSlash(dir), base+".md"), nil
+	default:
+		return filepath.Join(root, site.ContentDir, dir, base+"."+locale+".md"), nil
+	}
+}
@@ -3,20 +3,30 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 
-	"hugotranslationstudy/internal/piglatin"
+	"hugotranslationstudy/internal/config"
+	"hugotranslationstudy/internal/frontmatter"
+	"hugotranslationstudy/internal/manifest"
+	"hugotranslationstudy/internal/markdoc"
+	"hugotranslationstudy/internal/segment"
 	"hugotranslationstudy/internal/subtokenize"
+	"hugotranslationstudy/internal/tm"
 	"hugotranslationstudy/internal/tomarkdoc"
+	"hugotranslationstudy/internal/translate"
 
 	"github.com/gohugoio/hugo/parser/pageparser"
-	"gopkg.in/yaml.v3"
 )
 
 /*
@@ -24,9 +34,10 @@ A token created by Hugo's pageparser package. For example,
 the opening punctuation of a shortcode becomes a token.
 */
 type Token struct {
-	Type      string                  `json:"type"`
-	Val       string                  `json:"val"`
-	Subtokens []subtokenize.Subtoken  `json:"subtokens,omitempty"`
+	Type      string                 `json:"type"`
+	Val       string                 `json:"val"`
+	Subtokens []subtokenize.Subtoken `json:"subtokens,omitempty"`
+	Segments  []segment.Segment      `json:"segments,omitempty"` // the subtokens by sentence
 }
 
 type TextSpan struct {
@@ -35,20 +46,137 @@ type TextSpan struct {
 	Text  string `json:"text"`
 }
 
+// ParamSpan is a translatable shortcode parameter value in the body.
+// Start and End cover the raw value including any quotes, so the value
+// can be re-quoted on the way back in.
+type ParamSpan struct {
+	Start     int    `json:"start"`
+	End       int    `json:"end"`
+	Shortcode string `json:"shortcode"`
+	Param     string `json:"param"` // name, or position for positional params
+	Quote     string `json:"quote"` // `"`, "`" or "" when unquoted
+	Text      string `json:"text"`
+}
+
 type Output struct {
-	SourcePath       string                 `json:"sourcePath"`
-	FrontMatter      map[string]any         `json:"frontMatter"`
-	ContentRaw       string                 `json:"contentRaw"`
-	ContentTok       []Token                `json:"contentTokens"`
-	ContentTextSpans []TextSpan             `json:"contentTextSpans"`
+	SourcePath        string              `json:"sourcePath"`
+	FrontMatter       map[string]any      `json:"frontMatter"`
+	FrontMatterRaw    string              `json:"frontMatterRaw"`
+	ContentRaw        string              `json:"contentRaw"`
+	ContentTok        []Token             `json:"contentTokens"`
+	ContentTextSpans  []TextSpan          `json:"contentTextSpans"`
+	ContentParamSpans []ParamSpan         `json:"contentParamSpans,omitempty"`
+	FrontMatterFields []frontmatter.Field `json:"frontMatterFields,omitempty"`
+	TMMatches         []tm.Match          `json:"tmMatches,omitempty"` // fuzzy matches for segments sent to the translator
 }
 
+const (
+	defaultConfigPath = "translation.yaml"
+	defaultSchemaPath = "markdoc.yaml"
+	contentRoot       = "content"
+	outRoot           = "out"
+)
+
 func main() {
-	contentRoot := "content"
-	outRoot := "out"
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			runImport(os.Args[2:])
+			return
+		case "tm":
+			runTM(os.Args[2:])
+			return
+		case "frommarkdoc":
+			runFromMarkdoc(os.Args[2:])
+			return
+		case "qa":
+			runQA(os.Args[2:])
+			return
+		}
+	}
+
+	translatorName := flag.String("translator", "piglatin",
+		fmt.Sprintf("translation backend to use (one of %s)", strings.Join(translate.Names(), ", ")))
+	sourceLocale := flag.String("source-locale", "en", "locale of the content files")
+	targetLocale := flag.String("target-locale", "x-piglatin", "locale to translate into")
+	configPath := flag.String("config", defaultConfigPath, "translation config (YAML)")
+	export := flag.String("export", "", `also write interchange files ("xliff" or "po")`)
+	tmPath := flag.String("tm", "", "translation memory file (JSONL); reused and updated across runs")
+	poGranularity := flag.String("po-granularity", "file", `with -export po, write one POT per "file" or per "section"`)
+	locales := flag.String("locales", "", "comma-separated target locales (default: the languages of the Hugo site config, else -target-locale)")
+	layout := flag.String("layout", layoutFilename, `where translations go in out/hugo: by "filename" (post.fr.md) or by content "directory" (fr/post.md)`)
+	hugoConfig := flag.String("hugo-config", "", "Hugo site config to read languages from (default: hugo.toml, config.yaml, ... in the current directory)")
+	incremental := flag.Bool("incremental", false, "keep out/ and only redo sources and segments that changed since the last run")
+	placeholders := flag.Bool("placeholders", false, "send each sentence to the translator as one string, with its inline markup as {1}…{/1} placeholders")
+	schemaPath := flag.String("markdoc-config", defaultSchemaPath, "shortcode mapping and tag schema for the Markdoc migration (YAML or JSON)")
+	flag.Parse()
+
+	switch *export {
+	case "", "xliff", "po":
+	default:
+		log.Fatalf("unknown -export format %q", *export)
+	}
+	if *poGranularity != "file" && *poGranularity != "section" {
+		log.Fatalf("unknown -po-granularity %q", *poGranularity)
+	}
+	if *layout != layoutFilename && *layout != layoutDirectory {
+		log.Fatalf("unknown -layout %q", *layout)
+	}
+
+	sourceSet := false
+	flag.Visit(func(f *flag.Flag) { sourceSet = sourceSet || f.Name == "source-locale" })
+	setup, err := resolveLocales(*locales, *hugoConfig, *targetLocale, sourceLocale, sourceSet)
+	if err != nil {
+		log.Fatalf("locales: %v", err)
+	}
+	multi := len(setup.Locales) > 1
+
+	tr, err := translate.Lookup(*translatorName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var mem *tm.Memory
+	if *tmPath != "" {
+		if mem, err = tm.Open(*tmPath); err != nil {
+			log.Fatalf("translation memory: %v", err)
+		}
+	}
+
+	cfg, err := config.Load(*configPath)
+	if errors.Is(err, fs.ErrNotExist) && *configPath == defaultConfigPath {
+		// No config: nothing beyond body text is translated.
+	} else if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	seg := segment.New(*sourceLocale, cfg.Abbreviations)
 
-	// Clear the out folder if it exists
-	if _, err := os.Stat(outRoot); err == nil {
+	schema := loadMarkdocSchema(*schemaPath)
+	tags, err := markdoc.LoadSchema(*schemaPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatalf("markdoc config: %v", err)
+	}
+
+	// The manifest records what each output was built from. Entries made
+	// with other settings are not reused. The Markdoc mapping is not one of
+	// them: migrated.mdoc is redone on every run anyway.
+	cfgJSON, err := json.Marshal(cfg)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	settings := fmt.Sprintf("translator=%s source=%s targets=%s layout=%t/%s export=%s/%s placeholders=%t config=%s",
+		*translatorName, *sourceLocale, strings.Join(setup.Locales, ","), setup.WriteSite, *layout,
+		*export, *poGranularity, *placeholders, manifest.Hash(cfgJSON))
+	manifestPath := filepath.Join(outRoot, "manifest.json")
+	prev := manifest.New(settings)
+	next := manifest.New(settings)
+
+	if *incremental {
+		if prev, err = manifest.Load(manifestPath, settings); err != nil {
+			log.Fatalf("manifest: %v", err)
+		}
+	} else if _, err := os.Stat(outRoot); err == nil {
+		// Clear the out folder if it exists
 		if err := os.RemoveAll(outRoot); err != nil {
 			log.Fatalf("remove %s: %v", outRoot, err)
 		}
@@ -59,8 +187,15 @@ func main() {
 		log.Fatalf("mkdir %s: %v", outRoot, err)
 	}
 
-	var processed int
-	err := filepath.WalkDir(contentRoot, func(path string, d fs.DirEntry, walkErr error) error {
+	// Every page, for the links ref and relref shortcodes stand for
+	site := loadSite(contentRoot, setup, *sourceLocale)
+
+	var processed, unchanged, violations int
+	var review []string // {{% %}} shortcodes to check by hand after the migration
+	var report tomarkdoc.Report
+	sections := map[string][]Output{} // pages per section, for -po-granularity section
+	var sectionNames []string
+	err = filepath.WalkDir(contentRoot, func(path string, d fs.DirEntry, walkErr error) error {
 		if walkErr != nil {
 			return walkErr
 		}
@@ -68,25 +203,50 @@ func main() {
 			return nil
 		}
 		// only .md source files in content/
-		if !strings.HasSuffix(strings.ToLower(path), ".md") ||
-			strings.HasSuffix(strings.ToLower(path), ".translated.md") ||
-			strings.HasSuffix(strings.ToLower(path), ".mdoc") {
+		if !isSourcePage(path) {
 			return nil
 		}
 
 		// Mirror the folder structure from content -> out
-		rel, err := filepath.Rel(contentRoot, path)
+		targetDir, err := targetDirFor(contentRoot, outRoot, path)
 		if err != nil {
-			return fmt.Errorf("rel path: %w", err)
+			return err
 		}
-		relDir := filepath.Dir(rel)                           // e.g. blog/
-		base := strings.TrimSuffix(filepath.Base(rel), ".md") // e.g. post
-
-		// Target folder: out/blog/post/
-		targetDir := filepath.Join(outRoot, relDir, base)
+		base := filepath.Base(targetDir)
 		if err := os.MkdirAll(targetDir, 0o755); err != nil {
 			return fmt.Errorf("mkdir %s: %w", targetDir, err)
 		}
+		jsonOut := filepath.Join(targetDir, "data.json")
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		source, sourceHash := filepath.ToSlash(path), manifest.Hash(raw)
+		if *incremental && prev.Unchanged(source, sourceHash) {
+			fmt.Printf("Unchanged  %s\n", source)
+			next.Files[source] = prev.Files[source]
+			if *export == "po" && *poGranularity == "section" {
+				section := sectionOf(path)
+				if sections[section] == nil {
+					sectionNames = append(sectionNames, section)
+				}
+				sections[section] = append(sections[section], readOutput(jsonOut))
+			}
+			// Links to other pages may have changed; migrated.mdoc is redone.
+			page := readOutput(jsonOut)
+			mdoc, mdocFM, notes, err := migratePage(page, schema, site, contentPath(path))
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			mdocOut := filepath.Join(targetDir, "migrated.mdoc")
+			tomarkdoc.WriteMdocFile(mdocOut, mdocFM, mdoc.Body)
+			violations += validateMdoc(mdocOut, tags)
+			review = append(review, reviewEntries(source, raw, page.ContentRaw, mdoc.Review)...)
+			report.Add(source, mdoc, func(offset int) int { return bodyLine(raw, page.ContentRaw, offset) }, notes)
+			unchanged++
+			return nil
+		}
 
 		fmt.Printf("Processing %s -> %s\n", filepath.ToSlash(path), filepath.ToSlash(targetDir))
 
@@ -98,29 +258,90 @@ func main() {
 		fmt.Println("  Tokens:     ", filepath.ToSlash(dumpOut))
 
 		// 1–2: parse + write JSON -> data.json
-		jsonOut := filepath.Join(targetDir, "data.json")
-		_, outObj := parseAndWriteJSON(path, targetDir)
+		_, outObj := parseAndWriteJSON(path, targetDir, cfg, seg)
 		// parseAndWriteJSON currently writes <base>.json — rename/move if needed
 		if err := os.Rename(filepath.Join(targetDir, base+".json"), jsonOut); err != nil {
 			return fmt.Errorf("rename json: %w", err)
 		}
 
-		// 3–4: read JSON + translate
-		translatedBody := translateBodyUsingRanges(jsonOut)
-
-		// 5: write translated Markdown -> translated.md
-		mdOut := filepath.Join(targetDir, "translated.md")
-		writeHugoFile(mdOut, outObj.FrontMatter, translatedBody)
+		// 3–5: translate per locale -> translated.md (translated.<locale>.md
+		// with several locales), plus the Hugo site tree when enabled
+		var outputs []string
+		segments := map[string]map[string]string{}
+		for _, locale := range setup.Locales {
+			translatedFM, translatedBody, segs := translateBodyUsingRanges(jsonOut, tr, mem, prev.Files[source].Segments[locale], *sourceLocale, locale, *placeholders)
+			segments[locale] = segs
+
+			mdOut := filepath.Join(targetDir, localized("translated.md", locale, multi))
+			writeHugoFile(mdOut, translatedFM, translatedBody)
+			fmt.Println("  Translated: ", filepath.ToSlash(mdOut))
+			outputs = append(outputs, mdOut)
+
+			if setup.WriteSite {
+				siteOut, err := sitePath(path, locale, *sourceLocale, *layout, setup.Site)
+				if err != nil {
+					return err
+				}
+				if err := os.MkdirAll(filepath.Dir(siteOut), 0o755); err != nil {
+					return fmt.Errorf("mkdir %s: %w", filepath.Dir(siteOut), err)
+				}
+				writeHugoFile(siteOut, translatedFM, translatedBody)
+				fmt.Println("  Hugo:       ", filepath.ToSlash(siteOut))
+				outputs = append(outputs, siteOut)
+			}
+		}
 
 		// 6: convert ORIGINAL body to migrated.mdoc
-		mdocBody := tomarkdoc.ConvertBodyToMdocTokens(outObj.ContentRaw)
+		mdoc, mdocFM, notes, err := migratePage(outObj, schema, site, contentPath(path))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for _, d := range mdoc.Diagnostics {
+			fmt.Printf("  warning: %s:%d: %s\n", source, bodyLine(raw, outObj.ContentRaw, d.Offset), diagnosticText(d))
+		}
+		for _, n := range notes {
+			fmt.Printf("  front matter: %s: %s\n", source, n)
+		}
+		review = append(review, reviewEntries(source, raw, outObj.ContentRaw, mdoc.Review)...)
+		report.Add(source, mdoc, func(offset int) int { return bodyLine(raw, outObj.ContentRaw, offset) }, notes)
 		mdocOut := filepath.Join(targetDir, "migrated.mdoc")
-		tomarkdoc.WriteMdocFile(mdocOut, outObj.FrontMatter, mdocBody)
+		tomarkdoc.WriteMdocFile(mdocOut, mdocFM, mdoc.Body)
+		violations += validateMdoc(mdocOut, tags)
 
 		fmt.Println("  JSON:       ", filepath.ToSlash(jsonOut))
-		fmt.Println("  Translated: ", filepath.ToSlash(mdOut))
 		fmt.Println("  MDOC:       ", filepath.ToSlash(mdocOut))
+		outputs = append(outputs, dumpOut, jsonOut, mdocOut)
+
+		// Optional: interchange files for translators
+		switch {
+		case *export == "xliff":
+			for _, locale := range setup.Locales {
+				xlfOut := filepath.Join(targetDir, localized("data.xlf", locale, multi))
+				if err := writeXLIFF(xlfOut, outObj, *sourceLocale, locale); err != nil {
+					return err
+				}
+				fmt.Println("  XLIFF:      ", filepath.ToSlash(xlfOut))
+				outputs = append(outputs, xlfOut)
+			}
+		case *export == "po" && *poGranularity == "file":
+			potOut := filepath.Join(targetDir, "data.pot")
+			if err := writePOT(potOut, []Output{outObj}); err != nil {
+				return err
+			}
+			fmt.Println("  POT:        ", filepath.ToSlash(potOut))
+			outputs = append(outputs, potOut)
+		case *export == "po":
+			section := sectionOf(path)
+			if sections[section] == nil {
+				sectionNames = append(sectionNames, section)
+			}
+			sections[section] = append(sections[section], outObj)
+		}
 
+		for i := range outputs {
+			outputs[i] = filepath.ToSlash(outputs[i])
+		}
+		next.Files[source] = manifest.File{SourceHash: sourceHash, Outputs: outputs, Segments: segments}
 		processed++
 		return nil
 	})
@@ -128,13 +349,96 @@ func main() {
 		log.Fatal(err)
 	}
 
-	fmt.Printf("Done. Processed %d Markdown file(s).\n", processed)
+	for _, section := range sectionNames {
+		potOut := filepath.Join(outRoot, section+".pot")
+		if err := writePOT(potOut, sections[section]); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("POT:", filepath.ToSlash(potOut))
+	}
+
+	// Sources deleted since the last run leave stale outputs behind
+	for _, source := range prev.Sources() {
+		if _, ok := next.Files[source]; ok {
+			continue
+		}
+		for _, out := range prev.Files[source].Outputs {
+			if err := os.Remove(filepath.FromSlash(out)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Fatal(err)
+			}
+			removeEmptyDirs(filepath.Dir(filepath.FromSlash(out)), outRoot)
+		}
+		fmt.Printf("Removed outputs of %s\n", source)
+	}
+	if err := next.Save(manifestPath); err != nil {
+		log.Fatalf("manifest: %v", err)
+	}
+	writeReview(filepath.Join(outRoot, "markdoc-review.md"), review)
+	writeReport(filepath.Join(outRoot, "migration-report"), &report)
+
+	if mem != nil {
+		if err := mem.Save(); err != nil {
+			log.Fatalf("translation memory: %v", err)
+		}
+	}
+
+	if *incremental {
+		fmt.Printf("Done. Processed %d Markdown file(s), %d unchanged.\n", processed, unchanged)
+	} else {
+		fmt.Printf("Done. Processed %d Markdown file(s).\n", processed)
+	}
+	if violations > 0 {
+		log.Fatalf("%d Markdoc violation(s)", violations)
+	}
+}
+
+// validateMdoc checks a migrated file against the tag schema, prints each
+// violation as path:line:col and returns how many there were.
+func validateMdoc(path string, s markdoc.Schema) int {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("read %s: %v", path, err)
+	}
+	vs := markdoc.Validate(src, s)
+	for _, v := range vs {
+		fmt.Printf("  %s:%s\n", filepath.ToSlash(path), v)
+	}
+	return len(vs)
 }
 
+// isSourcePage reports whether path is a Markdown source page, not one of
+// the files this tool writes.
+func isSourcePage(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".md") && !strings.HasSuffix(lower, ".translated.md")
+}
+
+// targetDirFor mirrors a content path into the out folder:
+// content/blog/post.md -> out/blog/post.
+func targetDirFor(contentRoot, outRoot, path string) (string, error) {
+	rel, err := filepath.Rel(contentRoot, path)
+	if err != nil {
+		return "", fmt.Errorf("rel path: %w", err)
+	}
+	relDir := filepath.Dir(rel)                           // e.g. blog/
+	base := strings.TrimSuffix(filepath.Base(rel), ".md") // e.g. post
+	return filepath.Join(outRoot, relDir, base), nil
+}
+
+// removeEmptyDirs removes dir and its parents while they are empty, stopping
+// at stop.
+func removeEmptyDirs(dir, stop string) {
+	for dir != stop && dir != "." && dir != string(filepath.Separator) {
+		if err := os.Remove(dir); err != nil {
+			return // not empty (or already gone)
+		}
+		dir = filepath.Dir(dir)
+	}
+}
 
 // --- Step 1–2: Parse and JSON ---
 
-func parseAndWriteJSON(srcPath, outDir string) (string, Output) {
+func parseAndWriteJSON(srcPath, outDir string, cfg config.Config, seg *segment.Segmenter) (string, Output) {
 	raw, err := os.ReadFile(srcPath)
 	if err != nil {
 		log.Fatalf("read %s: %v", srcPath, err)
@@ -144,6 +448,12 @@ func parseAndWriteJSON(srcPath, outDir string) (string, Output) {
 	if err != nil {
 		log.Fatalf("ParseFrontMatterAndContent: %v", err)
 	}
+	// Everything before the content is the front matter block, delimiters
+	// included; keep it verbatim so it can be written back unchanged.
+	if !bytes.HasSuffix(raw, cf.Content) {
+		log.Fatalf("%s: content is not a suffix of the file", srcPath)
+	}
+	fmRaw := raw[:len(raw)-len(cf.Content)]
 
 	// Tokenize ONLY the body (no front matter)
 	contentRes, err := pageparser.ParseMain(bytes.NewReader(cf.Content), pageparser.Config{})
@@ -155,6 +465,11 @@ func parseAndWriteJSON(srcPath, outDir string) (string, Output) {
 
 	var bodyTokens []Token
 	var textSpans []TextSpan
+	var paramSpans []ParamSpan
+
+	// Shortcode state for picking out translatable parameter values
+	var scName, paramName string
+	var scPos int
 
 	for {
 		item := it.Next()
@@ -172,16 +487,35 @@ func parseAndWriteJSON(srcPath, outDir string) (string, Output) {
 		}
 
 		if tok.Type == "tText" && len(valB) > 0 {
-			subs, err := subtokenize.Subtokenize(valB)
+			subs, err := subtokenize.SubtokenizeAttributes(valB, htmlAttributes(cfg))
 			if err != nil {
 				log.Printf("warning: subtokenize failed: %v", err)
 			} else {
 				tok.Subtokens = subs
+				tok.Segments = seg.Split(val, subs)
 			}
 		}
 
 		bodyTokens = append(bodyTokens, tok)
 
+		switch tok.Type {
+		case "tScName":
+			scName, paramName, scPos = val, "", 0
+		case "tScParam":
+			if it.Peek().Type.String() == "tScParamVal" {
+				paramName = val // named: the value follows
+				break
+			}
+			if cfg.TranslatablePosition(scName, scPos) {
+				paramSpans = append(paramSpans, newParamSpan(src, item, scName, strconv.Itoa(scPos)))
+			}
+			scPos++
+		case "tScParamVal":
+			if cfg.TranslatableParam(scName, paramName) {
+				paramSpans = append(paramSpans, newParamSpan(src, item, scName, paramName))
+			}
+		}
+
 		if tok.Type == "tText" && len(valB) > 0 {
 			textSpans = append(textSpans, TextSpan{
 				Start: start,
@@ -192,15 +526,25 @@ func parseAndWriteJSON(srcPath, outDir string) (string, Output) {
 	}
 
 	out := Output{
-		SourcePath:       srcPath,
-		FrontMatter:      cf.FrontMatter,
-		ContentRaw:       string(cf.Content),
-		ContentTok:       bodyTokens,
-		ContentTextSpans: textSpans,
+		SourcePath:        srcPath,
+		FrontMatter:       cf.FrontMatter,
+		FrontMatterRaw:    string(fmRaw),
+		ContentRaw:        string(cf.Content),
+		ContentTok:        bodyTokens,
+		ContentTextSpans:  textSpans,
+		ContentParamSpans: paramSpans,
+		FrontMatterFields: frontmatter.Extract(cf.FrontMatter, cfg.FrontMatter),
 	}
 
 	base := strings.TrimSuffix(filepath.Base(srcPath), filepath.Ext(srcPath))
 	jsonPath := filepath.Join(outDir, base+".json")
+	n := writeOutput(jsonPath, out)
+	fmt.Printf("Wrote %s (%d bytes)\n", filepath.ToSlash(jsonPath), n)
+	return jsonPath, out
+}
+
+// writeOutput writes out as indented JSON and returns its size.
+func writeOutput(jsonPath string, out Output) int {
 	data, err := json.MarshalIndent(out, "", "  ")
 	if err != nil {
 		log.Fatalf("marshal: %v", err)
@@ -208,15 +552,44 @@ func parseAndWriteJSON(srcPath, outDir string) (string, Output) {
 	if err := os.WriteFile(jsonPath, data, 0o644); err != nil {
 		log.Fatalf("write %s: %v", jsonPath, err)
 	}
-	fmt.Printf("Wrote %s (%d bytes)\n", filepath.ToSlash(jsonPath), len(data))
-	return jsonPath, out
+	return len(data)
+}
+
+// newParamSpan records a shortcode parameter value. pageparser strips the
+// quotes (and unescapes \" inside them), so the raw extent is recovered from
+// the source bytes around the item.
+func newParamSpan(src []byte, item pageparser.Item, shortcode, param string) ParamSpan {
+	start := item.Pos()
+	val := item.ValStr(src)
+	end := start + len(val)
+	quote := ""
+	if start > 0 && (src[start-1] == '"' || src[start-1] == '`') {
+		quote = string(src[start-1])
+		start--
+		// Find the closing quote; only double quotes allow \" escapes.
+		end = start + 1
+		for end < len(src) && src[end] != quote[0] {
+			if quote == `"` && src[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		end++
+	}
+	return ParamSpan{
+		Start:     start,
+		End:       end,
+		Shortcode: shortcode,
+		Param:     param,
+		Quote:     quote,
+		Text:      val,
+	}
 }
 
 // --- Step 3–4: Translate using ranges ---
 
-// translateBodyUsingRanges reads the JSON, converts all text spans to Pig Latin,
-// and splices them back into the body using byte ranges.
-func translateBodyUsingRanges(jsonPath string) string {
+// readOutput loads a data.json written by parseAndWriteJSON.
+func readOutput(jsonPath string) Output {
 	b, err := os.ReadFile(jsonPath)
 	if err != nil {
 		log.Fatalf("read %s: %v", jsonPath, err)
@@ -225,49 +598,295 @@ func translateBodyUsingRanges(jsonPath string) string {
 	if err := json.Unmarshal(b, &in); err != nil {
 		log.Fatalf("unmarshal %s: %v", jsonPath, err)
 	}
+	return in
+}
 
-	body := []byte(in.ContentRaw)
-
-	// Build a list of tText tokens (in order) to match with text spans
-	var tTextTokens []Token
+// spanSubtokens returns the subtokens of each text span, nil where the span's
+// tText token could not be subtokenized.
+func spanSubtokens(in Output) [][]subtokenize.Subtoken {
+	// tText tokens (in order) line up with the text spans
+	subs := make([][]subtokenize.Subtoken, len(in.ContentTextSpans))
+	i := 0
 	for _, tok := range in.ContentTok {
-		if tok.Type == "tText" && len(tok.Val) > 0 {
-			tTextTokens = append(tTextTokens, tok)
+		if tok.Type == "tText" && len(tok.Val) > 0 && i < len(subs) {
+			subs[i] = tok.Subtokens
+			i++
 		}
 	}
+	return subs
+}
 
-	for i := len(in.ContentTextSpans) - 1; i >= 0; i-- {
-		span := in.ContentTextSpans[i]
-		if span.Start < 0 || span.End < 0 || span.Start > span.End || span.End > len(body) {
-			log.Fatalf("invalid span range: %d..%d (len=%d)", span.Start, span.End, len(body))
-		}
-		if !utf8.Valid(body[span.Start:span.End]) {
-			log.Fatalf("span not valid utf8 at %d..%d", span.Start, span.End)
+// spanSegments returns the sentences of each text span, nil where the
+// span's tText token could not be subtokenized. Tokens from before
+// segmentation count as one sentence.
+func spanSegments(in Output) [][]segment.Segment {
+	segs := make([][]segment.Segment, len(in.ContentTextSpans))
+	i := 0
+	for _, tok := range in.ContentTok {
+		if tok.Type == "tText" && len(tok.Val) > 0 && i < len(segs) {
+			segs[i] = tok.Segments
+			if segs[i] == nil && len(tok.Subtokens) > 0 {
+				segs[i] = []segment.Segment{{Subtokens: tok.Subtokens}}
+			}
+			i++
 		}
+	}
+	return segs
+}
 
-		var translated string
-		if i < len(tTextTokens) && len(tTextTokens[i].Subtokens) > 0 {
-			// Use subtokens: translate only "text" subtokens, preserve "markup"
-			translated = translateWithSubtokens(tTextTokens[i].Subtokens)
+// translateBodyUsingRanges reads the JSON, sends all translatable segments to
+// tr in a single batch, and splices the results back into the body using
+// byte ranges. It returns the translated front matter and body.
+//
+// Segments found in prev (segment hash -> translation, from an earlier run)
+// are reused as they are. With a translation memory, segments it already
+// holds are not sent to tr either, and fuzzy matches for the others are added
+// to the JSON as tmMatches. The third result maps the hash of every segment
+// to its translation, for the manifest.
+//
+// With placeholders, each sentence is one segment, its inline markup written
+// as placeholders; a sentence whose translation breaks them is left as is.
+func translateBodyUsingRanges(jsonPath string, tr translate.Translator, mem *tm.Memory, prev map[string]string, sourceLocale, targetLocale string, placeholders bool) (*frontmatter.Document, string, map[string]string) {
+	in := readOutput(jsonPath)
+	subs := spanSegments(in)
+
+	// Gather the segments of every span into one batch, remembering where
+	// each span's segments start so the results can be split up again.
+	var batch []string
+	offsets := make([]int, len(in.ContentTextSpans)+1)
+	for i, span := range in.ContentTextSpans {
+		offsets[i] = len(batch)
+		if len(subs[i]) > 0 && placeholders {
+			// One segment per sentence, then its attribute values
+			for _, seg := range subs[i] {
+				text, attrs := segment.Placeholders(seg)
+				batch = append(batch, text)
+				batch = append(batch, attrs...)
+			}
+		} else if len(subs[i]) > 0 {
+			// Use subtokens: only "text" subtokens are translatable
+			for _, s := range segment.Flatten(subs[i]) {
+				if s.Type == "text" {
+					batch = append(batch, s.Val)
+				}
+			}
 		} else {
 			// Fallback: translate the whole span
-			translated = piglatin.ToPigLatin(string(body[span.Start:span.End]))
+			batch = append(batch, span.Text)
+		}
+	}
+	offsets[len(in.ContentTextSpans)] = len(batch)
+
+	// Shortcode parameter values follow, one segment each
+	paramOffset := len(batch)
+	for _, p := range in.ContentParamSpans {
+		batch = append(batch, p.Text)
+	}
+
+	// Then the front matter values
+	fmOffset := len(batch)
+	for _, f := range in.FrontMatterFields {
+		batch = append(batch, f.Text)
+	}
+
+	results, matches, err := translateWithMemory(batch, tr, mem, prev, sourceLocale, targetLocale)
+	if err != nil {
+		log.Fatalf("translate %s: %v", jsonPath, err)
+	}
+	if len(matches) > 0 {
+		in.TMMatches = append(in.TMMatches, matches...)
+		writeOutput(jsonPath, in)
+	}
+
+	spans := make([]string, len(in.ContentTextSpans))
+	for i := range in.ContentTextSpans {
+		spanResults := results[offsets[i]:offsets[i+1]]
+		if len(subs[i]) > 0 && placeholders {
+			spans[i] = translateWithPlaceholders(jsonPath, subs[i], spanResults)
+		} else if len(subs[i]) > 0 {
+			// Use subtokens: swap in translated "text", preserve "markup"
+			spans[i] = translateWithSubtokens(subs[i], spanResults)
+		} else {
+			spans[i] = spanResults[0]
+		}
+	}
+	segments := make(map[string]string, len(batch))
+	for i, seg := range batch {
+		segments[manifest.Hash([]byte(seg))] = results[i]
+	}
+	fm, body := applyTranslations(jsonPath, in, spans, results[paramOffset:fmOffset], results[fmOffset:])
+	return fm, body, segments
+}
+
+// translateWithMemory translates batch with tr, reusing translations from
+// prev (keyed by segment hash) and exact matches from mem, either of which
+// may be nil, and recording new translations in mem. Duplicate segments are
+// sent once. It returns the translations and the best fuzzy match of each
+// segment that had to be translated.
+func translateWithMemory(batch []string, tr translate.Translator, mem *tm.Memory, prev map[string]string, sourceLocale, targetLocale string) ([]string, []tm.Match, error) {
+	results := make([]string, len(batch))
+	var misses []string
+	missIdx := map[string][]int{}
+	var matches []tm.Match
+	for i, seg := range batch {
+		if t, ok := prev[manifest.Hash([]byte(seg))]; ok {
+			results[i] = t
+			continue
+		}
+		if mem != nil {
+			if t, ok := mem.Lookup(seg, sourceLocale, targetLocale); ok {
+				results[i] = t
+				continue
+			}
+		}
+		if _, seen := missIdx[seg]; !seen {
+			misses = append(misses, seg)
+			if mem != nil {
+				if m, ok := mem.Fuzzy(seg, sourceLocale, targetLocale, tm.DefaultMinScore); ok {
+					matches = append(matches, m)
+				}
+			}
 		}
+		missIdx[seg] = append(missIdx[seg], i)
+	}
+	if len(misses) == 0 {
+		return results, matches, nil
+	}
+
+	translated, err := tr.Translate(misses, sourceLocale, targetLocale)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(translated) != len(misses) {
+		return nil, nil, fmt.Errorf("got %d translations for %d segments", len(translated), len(misses))
+	}
+	for j, seg := range misses {
+		for _, i := range missIdx[seg] {
+			results[i] = translated[j]
+		}
+		if mem != nil {
+			mem.Add(seg, translated[j], sourceLocale, targetLocale)
+		}
+	}
+	return results, matches, nil
+}
+
+// applyTranslations splices translated span and parameter texts into the body
+// and sets the translated front matter values. Each slice runs parallel to
+// the corresponding list in `in`.
+func applyTranslations(jsonPath string, in Output, spans, params, fields []string) (*frontmatter.Document, string) {
+	var edits []rangeEdit
+	for i, span := range in.ContentTextSpans {
+		edits = append(edits, rangeEdit{Start: span.Start, End: span.End, Val: spans[i]})
+	}
+	for i, p := range in.ContentParamSpans {
+		edits = append(edits, rangeEdit{Start: p.Start, End: p.End, Val: quoteParamValue(params[i], p.Quote)})
+	}
+
+	fm, err := frontmatter.Parse([]byte(in.FrontMatterRaw))
+	if err != nil {
+		log.Fatalf("%s: %v", jsonPath, err)
+	}
+	for i, f := range in.FrontMatterFields {
+		if err := fm.Set(f.Path, fields[i]); err != nil {
+			log.Printf("warning: %s: %v", jsonPath, err)
+		}
+	}
+	return fm, string(spliceEdits([]byte(in.ContentRaw), edits))
+}
+
+// rangeEdit replaces body[Start:End] with Val.
+type rangeEdit struct {
+	Start, End int
+	Val        string
+}
 
-		before := append([]byte(nil), body[:span.Start]...)
-		after := append([]byte(nil), body[span.End:]...)
-		body = append(before, []byte(translated)...)
+// spliceEdits applies non-overlapping edits to body, back to front so that
+// earlier byte offsets stay valid.
+func spliceEdits(body []byte, edits []rangeEdit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Start < edits[j].Start })
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		if e.Start < 0 || e.End < 0 || e.Start > e.End || e.End > len(body) {
+			log.Fatalf("invalid span range: %d..%d (len=%d)", e.Start, e.End, len(body))
+		}
+		if !utf8.Valid(body[e.Start:e.End]) {
+			log.Fatalf("span not valid utf8 at %d..%d", e.Start, e.End)
+		}
+
+		before := append([]byte(nil), body[:e.Start]...)
+		after := append([]byte(nil), body[e.End:]...)
+		body = append(before, []byte(e.Val)...)
 		body = append(body, after...)
 	}
-	return string(body)
+	return body
+}
+
+// quoteParamValue renders a translated shortcode parameter value with the
+// quoting it had in the source. Raw (backtick) strings that now contain a
+// backtick, and unquoted values that are no longer a single word, fall back
+// to double quotes.
+func quoteParamValue(val, quote string) string {
+	switch {
+	case quote == "`" && !strings.Contains(val, "`"):
+		return "`" + val + "`"
+	case quote == "" && val != "" && strings.IndexFunc(val, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '.'
+	}) < 0:
+		return val
+	}
+	return `"` + strings.ReplaceAll(val, `"`, `\"`) + `"`
 }
 
-// translateWithSubtokens translates only "text" subtokens and concatenates all.
-func translateWithSubtokens(subs []subtokenize.Subtoken) string {
+// htmlAttributes returns the HTML attributes whose values cfg translates.
+func htmlAttributes(cfg config.Config) []string {
+	if cfg.HTMLAttributes == nil {
+		return subtokenize.DefaultAttributes
+	}
+	return cfg.HTMLAttributes
+}
+
+// translateWithSubtokens puts the sentences of a span back together,
+// replacing each "text" subtoken with the next entry of translated.
+func translateWithSubtokens(segs []segment.Segment, translated []string) string {
+	subs := segment.Flatten(segs)
+	next := 0
+	for i := range subs {
+		if subs[i].Type == "text" {
+			subs[i].Val = translated[next]
+			next++
+		}
+	}
+	return joinSubtokens(subs)
+}
+
+// translateWithPlaceholders puts the sentences of a span back together from
+// translated, which holds for each sentence the translation of its
+// placeholder string followed by those of its attribute values. A sentence
+// whose placeholders cannot be restored keeps its source text.
+func translateWithPlaceholders(jsonPath string, segs []segment.Segment, translated []string) string {
+	var subs []subtokenize.Subtoken
+	next := 0
+	for _, seg := range segs {
+		_, attrs := segment.Placeholders(seg)
+		restored, err := segment.Restore(seg, translated[next], translated[next+1:next+1+len(attrs)])
+		if err != nil {
+			log.Printf("warning: %s: %v; keeping the source sentence", jsonPath, err)
+			restored = seg.Subtokens
+		}
+		subs = append(subs, restored...)
+		next += 1 + len(attrs)
+	}
+	return joinSubtokens(subs)
+}
+
+// joinSubtokens writes out translated subtokens. Translations of HTML
+// attribute values are escaped for their quotes.
+func joinSubtokens(subs []subtokenize.Subtoken) string {
 	var buf strings.Builder
-	for _, s := range subs {
-		if s.Type == "text" {
-			buf.WriteString(piglatin.ToPigLatin(s.Val))
+	for i, s := range subs {
+		if s.Type == "text" && s.Attr != "" && i > 0 {
+			buf.WriteString(subtokenize.EscapeAttribute(s.Val, subs[i-1].Val))
 		} else {
 			buf.WriteString(s.Val)
 		}
@@ -275,18 +894,13 @@ func translateWithSubtokens(subs []subtokenize.Subtoken) string {
 	return buf.String()
 }
 
-
 // --- Step 5: Write Markdown (.md) ---
 
-func writeHugoFile(outPath string, frontMatter map[string]any, body string) {
-	fm, err := yaml.Marshal(frontMatter)
-	if err != nil {
-		log.Fatalf("yaml marshal: %v", err)
-	}
+// writeHugoFile writes the front matter in its original syntax, followed by
+// the body.
+func writeHugoFile(outPath string, frontMatter *frontmatter.Document, body string) {
 	var buf bytes.Buffer
-	buf.WriteString("---\n")
-	buf.Write(fm)
-	buf.WriteString("---\n")
+	buf.Write(frontMatter.Bytes())
 	buf.WriteString(body)
 
 	if err := os.WriteFile(outPath, buf.Bytes(), 0o644); err != nil {
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"hugotranslationstudy/internal/frommarkdoc"
+	"hugotranslationstudy/internal/frontmatter"
+	"hugotranslationstudy/internal/tomarkdoc"
+
+	"github.com/gohugoio/hugo/parser/pageparser"
+)
+
+// loadMarkdocSchema reads the shortcode mapping. A missing default file
+// gives an empty mapping.
+func loadMarkdocSchema(path string) tomarkdoc.Schema {
+	schema, err := tomarkdoc.LoadSchema(path)
+	if errors.Is(err, fs.ErrNotExist) && path == defaultSchemaPath {
+		// No schema: positional parameters stay positional.
+	} else if err != nil {
+		log.Fatalf("markdoc config: %v", err)
+	}
+	return schema
+}
+
+// runFromMarkdoc converts .mdoc files back to Hugo shortcodes, writing
+// page.md next to each page.mdoc.
+func runFromMarkdoc(args []string) {
+	fs := flag.NewFlagSet("frommarkdoc", flag.ExitOnError)
+	schemaPath := fs.String("markdoc-config", defaultSchemaPath, "shortcode mapping to reverse (YAML or JSON)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s frommarkdoc [-markdoc-config markdoc.yaml] file.mdoc...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	schema := loadMarkdocSchema(*schemaPath)
+
+	for _, path := range fs.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("read %s: %v", path, err)
+		}
+		hugo, diags := frommarkdoc.Convert(string(src), schema)
+		for _, d := range diags {
+			line := 1 + strings.Count(string(src[:d.Offset]), "\n")
+			fmt.Printf("  warning: %s:%d: %s\n", filepath.ToSlash(path), line, diagnosticText(d))
+		}
+		out := strings.TrimSuffix(path, filepath.Ext(path)) + ".md"
+		if err := os.WriteFile(out, []byte(hugo), 0o644); err != nil {
+			log.Fatalf("write %s: %v", out, err)
+		}
+		fmt.Printf("Converted %s -> %s\n", filepath.ToSlash(path), filepath.ToSlash(out))
+	}
+}
+
+// loadSite reads the front matter of every page under root. File names may
+// end in the source locale, a target locale or a language of the site.
+func loadSite(root string, setup localeSetup, sourceLocale string) *tomarkdoc.Site {
+	langs := append([]string(nil), setup.Locales...)
+	for _, l := range setup.Site.Languages {
+		langs = append(langs, l.Code)
+	}
+	site := tomarkdoc.NewSite(setup.Site.BaseURL, sourceLocale, langs)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isSourcePage(path) {
+			return err
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		cf, err := pageparser.ParseFrontMatterAndContent(bytes.NewReader(raw))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		site.AddPage(contentPath(path), cf.FrontMatter)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("content: %v", err)
+	}
+	return site
+}
+
+// contentPath is the path of a page from the content root, as refs give it.
+func contentPath(path string) string {
+	rel, err := filepath.Rel(contentRoot, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// migratePage converts the body and front matter of the page at file to
+// Markdoc. It returns the converted body with what was met on the way, the
+// new front matter block and the notes from its transform.
+func migratePage(page Output, schema tomarkdoc.Schema, site *tomarkdoc.Site, file string) (tomarkdoc.Result, []byte, []string, error) {
+	mdoc := tomarkdoc.ConvertPage(page.ContentRaw, schema, site, file)
+	fm, err := frontmatter.Parse([]byte(page.FrontMatterRaw))
+	if err != nil {
+		return mdoc, nil, nil, err
+	}
+	block, notes, err := tomarkdoc.TransformFrontMatter(fm, schema.FrontMatter)
+	return mdoc, block, notes, err
+}
+
+// bodyLine returns the line in the source file raw of an offset in its
+// body.
+func bodyLine(raw []byte, body string, offset int) int {
+	return 1 + bytes.Count(raw[:len(raw)-len(body)+offset], []byte("\n"))
+}
+
+// diagnosticText prefixes a diagnostic with its shortcode, if it has one.
+func diagnosticText(d tomarkdoc.Diagnostic) string {
+	if d.Shortcode == "" {
+		return d.Message
+	}
+	return d.Shortcode + ": " + d.Message
+}
+
+// reviewEntries formats the review list of one page as Markdown list items.
+func reviewEntries(source string, raw []byte, body string, review []tomarkdoc.Diagnostic) []string {
+	var out []string
+	for _, r := range review {
+		out = append(out, fmt.Sprintf("- `%s:%d` %s: %s", source, bodyLine(raw, body, r.Offset), r.Shortcode, r.Message))
+	}
+	return out
+}
+
+// writeReview writes the list of shortcodes to check by hand after the
+// migration.
+func writeReview(path string, entries []string) {
+	var b strings.Builder
+	b.WriteString("# Markdoc migration review\n\n")
+	b.WriteString("Shortcodes written with `{{% %}}` whose content Hugo renders as Markdown. Check that the Markdoc tags render their children the same way.\n\n")
+	if len(entries) == 0 {
+		b.WriteString("Nothing to review.\n")
+	}
+	for _, e := range entries {
+		b.WriteString(e + "\n")
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		log.Fatalf("write %s: %v", path, err)
+	}
+	fmt.Printf("Review:      %s (%d shortcode(s))\n", filepath.ToSlash(path), len(entries))
+}
+
+// writeReport writes the migration report as base.json and base.md.
+func writeReport(base string, report *tomarkdoc.Report) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Fatalf("migration report: %v", err)
+	}
+	for path, data := range map[string][]byte{
+		base + ".json": append(data, '\n'),
+		base + ".md":   []byte(report.Markdown()),
+	} {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			log.Fatalf("write %s: %v", path, err)
+		}
+	}
+	fmt.Printf("Report:      %s.{json,md} (%d file(s))\n", filepath.ToSlash(base), len(report.Files))
+}
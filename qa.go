@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"hugotranslationstudy/internal/config"
+	"hugotranslationstudy/internal/qa"
+)
+
+// runQA implements `hugotranslationstudy qa`: it checks the translations in
+// XLIFF or PO deliveries against the source text in each page's data.json,
+// writes out/qa-report.{json,md} and exits with status 1 if it found errors.
+func runQA(args []string) {
+	flags := flag.NewFlagSet("qa", flag.ExitOnError)
+	configPath := flags.String("config", defaultConfigPath, "translation config (YAML), for htmlAttributes")
+	base := flags.String("o", filepath.Join(outRoot, "qa-report"), "report to write, as <o>.json and <o>.md")
+	strict := flags.Bool("strict", false, "also exit with status 1 on warnings")
+	minRatio := flags.Float64("min-ratio", 0.5, "warn when a translation is shorter than this share of its source")
+	maxRatio := flags.Float64("max-ratio", 2, "warn when a translation is longer than this multiple of its source (0: no limit)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s qa [flags] file.xlf|file.po...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if errors.Is(err, fs.ErrNotExist) && *configPath == defaultConfigPath {
+		// No config: the default HTML attributes are text.
+	} else if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	checker := qa.New(htmlAttributes(cfg))
+	checker.MinRatio, checker.MaxRatio = *minRatio, *maxRatio
+
+	report := qa.Report{Findings: []qa.Finding{}}
+	for _, path := range flags.Args() {
+		var d delivery
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".po":
+			d, err = loadPOUnits(path)
+		default:
+			d, err = loadXLIFFUnits(path)
+		}
+		if err != nil {
+			report.AddIssue(filepath.ToSlash(path), qa.Issue{Check: "file", Severity: qa.Error, Message: err.Error()})
+			continue
+		}
+		for _, page := range d.Pages {
+			checkPage(&report, checker, page, d.Units[page], d.Damaged[page])
+		}
+	}
+
+	writeQAReport(*base, &report)
+	if report.Errors > 0 || *strict && report.Warnings > 0 {
+		os.Exit(1)
+	}
+}
+
+// checkPage checks the translated units of one page, keyed by unit id,
+// against its data.json. Units in damaged had their inline codes dropped,
+// repeated or made up; that is their markup issue.
+func checkPage(report *qa.Report, checker *qa.Checker, page string, units map[string]string, damaged map[string]error) {
+	in := readOutput(pageJSON(page))
+	file := in.FrontMatterRaw + in.ContentRaw
+	line := func(bodyOffset int) int {
+		return strings.Count(file[:len(in.FrontMatterRaw)+bodyOffset], "\n") + 1
+	}
+	check := func(f qa.Finding, markdown bool) {
+		f.Page = page
+		target, ok := units[f.Unit]
+		if !ok {
+			report.Add(f, checker.Missing(f.Source, markdown))
+			return
+		}
+		f.Target = target
+		issues := checker.Check(f.Source, target, markdown)
+		if err, ok := damaged[f.Unit]; ok {
+			issues = slices.DeleteFunc(issues, func(is qa.Issue) bool { return is.Check == "markup" })
+			issues = append([]qa.Issue{{Check: "markup", Severity: qa.Error, Message: err.Error()}}, issues...)
+		}
+		report.Add(f, issues)
+	}
+
+	for i, subs := range spanSubtokens(in) {
+		span := in.ContentTextSpans[i]
+		lo, _, ok := spanCore(span.Text, subs)
+		if !ok {
+			continue
+		}
+		check(qa.Finding{Unit: unitText + strconv.Itoa(i), Line: line(span.Start + lo), Source: span.Text}, true)
+	}
+	for i, p := range in.ContentParamSpans {
+		check(qa.Finding{Unit: unitParam + strconv.Itoa(i), Line: line(p.Start), Source: p.Text}, false)
+	}
+	for i, f := range in.FrontMatterFields {
+		check(qa.Finding{Unit: unitFM + strconv.Itoa(i), Source: f.Text}, false)
+	}
+}
+
+// writeQAReport writes report as <base>.json and <base>.md.
+func writeQAReport(base string, report *qa.Report) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Fatalf("qa report: %v", err)
+	}
+	for path, data := range map[string][]byte{
+		base + ".json": append(data, '\n'),
+		base + ".md":   []byte(report.Markdown()),
+	} {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			log.Fatalf("write %s: %v", path, err)
+		}
+	}
+	fmt.Printf("QA: %d translation(s), %d error(s), %d warning(s) -> %s.{json,md}\n",
+		report.Units, report.Errors, report.Warnings, filepath.ToSlash(base))
+}